@@ -1,22 +1,60 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"errors"
+	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"url-shortener/internal/config"
 	"url-shortener/internal/http-server/handlers/delete"
+	domainDelete "url-shortener/internal/http-server/handlers/domain/delete"
+	domainList "url-shortener/internal/http-server/handlers/domain/list"
+	domainSave "url-shortener/internal/http-server/handlers/domain/save"
+	healthHandler "url-shortener/internal/http-server/handlers/health"
 	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/url/get"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/qr"
 	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/handlers/url/window"
+	utmDelete "url-shortener/internal/http-server/handlers/utm/delete"
+	utmList "url-shortener/internal/http-server/handlers/utm/list"
+	utmSave "url-shortener/internal/http-server/handlers/utm/save"
 	"url-shortener/internal/http-server/middleware/mwLogger"
+	"url-shortener/internal/http-server/middleware/mwMetrics"
+	"url-shortener/internal/http-server/middleware/mwTracing"
+	"url-shortener/internal/lib/clientip"
+	"url-shortener/internal/lib/geoip"
+	"url-shortener/internal/lib/health"
 	"url-shortener/internal/lib/logger/handlers/slogpretty"
+	"url-shortener/internal/lib/logger/handlers/slogtrace"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/lru"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/ratelimit"
+	"url-shortener/internal/lib/rollup"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/lib/tlsconf"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/lib/visitor"
+	"url-shortener/internal/storage/cache"
+	"url-shortener/internal/storage/coalesce"
 	"url-shortener/internal/storage/sqlite"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -25,11 +63,12 @@ const (
 	envProd  = "prod"
 )
 
+// сколько ссылок с паролем помнит ограничитель попыток
+const passwordLimiterSize = 10000
+
 func main() {
 	cfg := config.MustLoad()
 
-	fmt.Println(cfg)
-
 	log := setupLogger(cfg.Env)
 
 	// какая то инфа с логера, и выводим также окружение, которое используется
@@ -37,15 +76,89 @@ func main() {
 	log.Info("starting url-shortener", slog.String("env", cfg.Env))
 	log.Debug("debug messages are enabled")
 
+	// трейсинг, спаны дописываются при остановке сервиса
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Error("failed to init tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
 	// создаём хранилище
-	storage, err := sqlite.New(cfg.StoragePath)
+	storage, err := sqlite.New(cfg.StoragePath, sqlite.Options{
+		MaxOpenConns:    cfg.Storage.MaxOpenConns,
+		MaxIdleConns:    cfg.Storage.MaxIdleConns,
+		ConnMaxLifetime: cfg.Storage.ConnMaxLifetime,
+		BusyTimeout:     cfg.Storage.BusyTimeout,
+	})
 	if err != nil {
 		// Err() вернёт ключ-значение для вывода ошибки
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
 
-	_ = storage
+	if !redirect.ValidStatus(cfg.Redirect.DefaultStatus) {
+		log.Error("invalid default redirect status", slog.Int("status", cfg.Redirect.DefaultStatus))
+		os.Exit(1)
+	}
+
+	// секрет для cookie ссылок с паролем
+	cookieSecret := []byte(cfg.Redirect.Password.CookieSecret)
+	if len(cookieSecret) == 0 {
+		log.Warn("redirect cookie secret is not set, password cookies will be reset on restart")
+
+		cookieSecret = make([]byte, 32)
+		if _, err := rand.Read(cookieSecret); err != nil {
+			log.Error("failed to generate cookie secret", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	// своя страница для браузера, если ссылку открыть нельзя, без неё - встроенная
+	var notFoundPage *template.Template
+	if cfg.Redirect.NotFoundPage != "" {
+		notFoundPage, err = template.ParseFiles(cfg.Redirect.NotFoundPage)
+		if err != nil {
+			log.Error("failed to parse not found page", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	// ip клиента за прокси и страна по нему для адресов ссылки с условием countries
+	clientIP, err := clientip.New(cfg.GeoIP.TrustedProxies)
+	if err != nil {
+		log.Error("invalid trusted proxies", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var geoDB *geoip.DB
+	if cfg.GeoIP.DatabasePath != "" {
+		geoDB, err = geoip.Open(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			log.Error("failed to open geoip database", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	// одновременные запросы одного алиаса идут в хранилище одним запросом
+	urlGroup := coalesce.New(storage)
+	metrics.RegisterCoalesced(urlGroup.Coalesced)
+
+	// кэш перед хранилищем для редиректов, сбрасывается при изменении url
+	var urlGetter redirect.URLGetter = urlGroup
+	if cfg.Cache.Size > 0 {
+		urlCache := cache.New(urlGroup, cfg.Cache.Size, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
+		storage.OnChange(urlCache.Invalidate)
+		urlGetter = urlCache
+	}
+
+	// проверки готовности для /readyz
+	checker := health.NewChecker()
+	checker.Add("storage", storage.Ping)
+	checker.Add("migrations", storage.CheckMigrations)
 
 	// создали новый роутер
 	router := chi.NewRouter()
@@ -53,10 +166,14 @@ func main() {
 	// middleware
 	// для каждого запроса будет свой id, чтобы проще отлавливать ошибку
 	router.Use(middleware.RequestID)
+	// спан на каждый запрос, продолжает трейс из traceparent
+	router.Use(mwTracing.New())
 	// логгер из коробки chi
 	router.Use(middleware.Logger)
 	// свой логер
 	router.Use(mwLogger.New(log))
+	// метрики запросов
+	router.Use(mwMetrics.New())
 	// если паника внутри хендлера, то будем востанавливать эту панику, чтобы приложение не упало
 	router.Use(middleware.Recoverer)
 	// чтобы urlы были красивыми
@@ -65,23 +182,109 @@ func main() {
 	// наш собственный роутер для авторизации (права доступа)
 	// он будет работать только для /url
 	// POST /usr - сохранить url
+	// GET /url - список url
+	// PUT /url/{alias} - изменить url
 	// DELETE /usr/{alias} - удалить url
-	// GET /{alias} - получить url
+	// GET /url/{alias}/stats - статистика переходов
+	// PUT /url/{alias}/window - изменить время работы ссылки
+	// GET /url/{alias}/qr - qr код короткой ссылки
+	// алиасы своего домена передаются с ?domain=
+	// GET /{alias} - получить url, домен берётся из Host
+	// готовые qr коды, картинка зависит только от короткой ссылки и параметров
+	qrOpts := qr.Options{BaseURL: cfg.QR.BaseURL, MaxSize: cfg.QR.MaxSize}
+	if cfg.QR.CacheSize > 0 {
+		qrOpts.Cache = lru.New[string, []byte](cfg.QR.CacheSize)
+	}
+
+	basicAuth := middleware.BasicAuth("url-shortener", map[string]string{
+		cfg.HTTPServer.User: cfg.HTTPServer.Password,
+	})
+	// пути верхнего уровня нельзя занять алиасом: новый путь нужно добавить в reservedAliases (handlers/url/save)
 	router.Route("/url", func(r chi.Router) {
-		r.Use(middleware.BasicAuth("url-shortener", map[string]string{
-			cfg.HTTPServer.User: cfg.HTTPServer.Password,
-		}))
+		r.Use(basicAuth)
 		// запрос на сохранение урла
-		r.Post("/", save.New(log, storage))
+		r.Post("/", save.New(log, storage, storage))
+
+		// запрос на получение списка url
+		r.Get("/", list.New(log, storage))
+
+		// запрос на получение ссылки без перехода по ней
+		r.Get("/{alias}", get.New(log, storage))
+
+		// запрос на изменение url
+		r.Put("/{alias}", update.New(log, storage))
 
 		// запрос на удаление url
 		r.Delete("/{alias}", delete.New(log, storage))
+
+		// запрос на получение статистики
+		r.Get("/{alias}/stats", stats.New(log, storage))
+
+		// запрос на изменение времени работы ссылки
+		r.Put("/{alias}/window", window.New(log, storage))
+
+		// qr код короткой ссылки (png или svg)
+		r.Get("/{alias}/qr", qr.New(log, urlGetter, qrOpts))
 	})
 
+	// POST /domain - зарегистрировать домен
+	// GET /domain - список доменов
+	// DELETE /domain/{host} - удалить домен
+	router.Route("/domain", func(r chi.Router) {
+		r.Use(basicAuth)
+
+		r.Post("/", domainSave.New(log, storage))
+		r.Get("/", domainList.New(log, storage))
+		r.Delete("/{host}", domainDelete.New(log, storage))
+	})
+
+	// POST /utm - сохранить шаблон utm параметров
+	// GET /utm - список шаблонов
+	// DELETE /utm/{name} - удалить шаблон
+	router.Route("/utm", func(r chi.Router) {
+		r.Use(basicAuth)
+
+		r.Post("/", utmSave.New(log, storage))
+		r.Get("/", utmList.New(log, storage))
+		r.Delete("/{name}", utmDelete.New(log, storage))
+	})
+
+	// метрики приложения в формате prometheus, под той же авторизацией, что и api:
+	// сервер публичный, а в метриках видно нагрузку и ошибки сервиса
+	router.With(basicAuth).Handle("/metrics", promhttp.Handler())
+
+	// проверки для оркестратора: процесс жив / готов принимать трафик
+	router.Get("/healthz", healthHandler.NewLiveness())
+	router.Get("/readyz", healthHandler.NewReadiness(log, checker))
+
 	// запрос на получение  url
-	router.Get("/{alias}", redirect.New(log, storage))
+	// принимаем любой метод: 307 и 308 должны сохранять метод, например POST от api клиентов
+	// /{alias}/* - путь после алиаса передаётся в целевой url, если ссылка это разрешает
+	// /{alias}+ и ?preview=1 - страница просмотра вместо перехода
+	redirectOpts := redirect.Options{
+		DefaultStatus:     cfg.Redirect.DefaultStatus,
+		PermanentMaxAge:   cfg.Redirect.PermanentMaxAge,
+		ComingSoonURL:     cfg.Redirect.ComingSoonURL,
+		FallbackURL:       cfg.Redirect.FallbackURL,
+		NotFoundPage:      notFoundPage,
+		Signer:            signer.New(cookieSecret),
+		PasswordCookieTTL: cfg.Redirect.Password.CookieTTL,
+		PasswordLimiter:   ratelimit.New(cfg.Redirect.Password.MaxAttempts, cfg.Redirect.Password.AttemptWindow, passwordLimiterSize),
+		ClientIP:          clientIP,
+		VariantCookieTTL:  cfg.Redirect.VariantCookieTTL,
+		Visitors:          visitor.NewHasher(storage),
+	}
+	// nil *geoip.DB в интерфейсе не был бы nil
+	if geoDB != nil {
+		redirectOpts.GeoIP = geoDB
+	}
+	redirectOpts.Preview = redirect.NewPreview(log, urlGetter, storage, redirectOpts)
+	redirectHandler := redirect.New(log, urlGetter, storage, redirectOpts)
+	router.Get("/{alias}+", redirectOpts.Preview)
+	router.HandleFunc("/{alias}", redirectHandler)
+	router.HandleFunc("/{alias}/*", redirectHandler)
 
-	log.Info("starting server", slog.String("address", cfg.Address))
+	log.Info("starting server", slog.String("address", cfg.Address), slog.Bool("tls", cfg.HTTPServer.TLS.Enabled))
 
 	// создаем сервер
 	srv := &http.Server{
@@ -92,12 +295,132 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.Timeout,
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server")
+	// останавливаемся по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// серверы, которые нужно остановить при выходе
+	servers := []*http.Server{srv}
+
+	// переходы старше raw_retention сворачиваются в дневные агрегаты в фоне, воркер участвует в проверке готовности
+	if cfg.Stats.RawRetention > 0 {
+		roller := rollup.New(storage, cfg.Stats.RawRetention, cfg.Stats.RollupInterval)
+		go roller.Run(ctx, log)
+		checker.Add("clicks_rollup", roller.Check)
+	}
+
+	if cfg.HTTPServer.TLS.Enabled {
+		tlsCfg := cfg.HTTPServer.TLS
+
+		// сертификат перечитывается в фоне, если не отключено, воркер участвует в проверке готовности
+		reloader, err := tlsconf.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			log.Error("failed to load certificate", sl.Err(err))
+			os.Exit(1)
+		}
+		if tlsCfg.ReloadInterval > 0 {
+			go reloader.Run(ctx, log, tlsCfg.ReloadInterval)
+			checker.Add("tls_reloader", reloader.Check)
+		}
+
+		srv.TLSConfig, err = tlsconf.New(tlsconf.Options{
+			MinVersion:   tlsCfg.MinVersion,
+			CipherSuites: tlsCfg.CipherSuites,
+		}, reloader.GetCertificate)
+		if err != nil {
+			log.Error("failed to init tls", sl.Err(err))
+			os.Exit(1)
+		}
+
+		// http -> https
+		if tlsCfg.RedirectAddress != "" {
+			redirectSrv := &http.Server{
+				Addr:         tlsCfg.RedirectAddress,
+				Handler:      httpsRedirect(cfg.Address),
+				ReadTimeout:  cfg.HTTPServer.Timeout,
+				WriteTimeout: cfg.HTTPServer.Timeout,
+				IdleTimeout:  cfg.HTTPServer.Timeout,
+			}
+			servers = append(servers, redirectSrv)
+
+			log.Info("starting https redirect server", slog.String("address", tlsCfg.RedirectAddress))
+
+			go func() {
+				if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Error("failed to start redirect server", sl.Err(err))
+					stop()
+				}
+			}()
+		}
+	}
+
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			// сертификат берётся из TLSConfig, HTTP/2 включается автоматически
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to start server", sl.Err(err))
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("stopping server")
+
+	// readyz начинает отвечать 503, даём балансировщику время убрать сервис из трафика
+	checker.SetDraining()
+	time.Sleep(cfg.HTTPServer.DrainDelay)
+
+	// дорабатываем текущие запросы
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Error("failed to stop server", slog.String("address", s.Addr), sl.Err(err))
+		}
 	}
 
 	// сервер остановлен
-	log.Error("server stopped")
+	log.Info("server stopped")
+
+	if err := storage.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
+
+	if geoDB != nil {
+		if err := geoDB.Close(); err != nil {
+			log.Error("failed to close geoip database", sl.Err(err))
+		}
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error("failed to shutdown tracing", sl.Err(err))
+	}
+}
+
+// перенаправляет http запросы на тот же хост и путь по https
+// порт берётся из адреса https сервера, 443 не указывается
+func httpsRedirect(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
 }
 
 // функция возвращает логер, который будет зависеть от env, то есть для каждой среды окружения(prod, local, dev) свой логер
@@ -109,9 +432,9 @@ func setupLogger(env string) *slog.Logger {
 		// возвращаем "красивый" логер
 		log = setupPrettySlog()
 	case envDev:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		log = slog.New(slogtrace.NewTraceHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	case envProd:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		log = slog.New(slogtrace.NewTraceHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
 	}
 
 	return log
@@ -126,5 +449,6 @@ func setupPrettySlog() *slog.Logger {
 
 	handler := opts.NewPrettyHandler(os.Stdout)
 
-	return slog.New(handler)
+	// trace_id и span_id добавляются в каждую запись с контекстом
+	return slog.New(slogtrace.NewTraceHandler(handler))
 }
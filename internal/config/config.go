@@ -11,9 +11,80 @@ import (
 // всё тоже самое как в yaml
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"local"` // теги для считывания c yaml
-	StoragePath string `yaml:"storage_path" env-requaired:"true"`
+	Env         string  `yaml:"env" env-default:"local"` // теги для считывания c yaml
+	StoragePath string  `yaml:"storage_path" env-requaired:"true"`
+	Storage     Storage `yaml:"storage"`
 	HTTPServer  `yaml:"http_server"`
+	Cache       Cache    `yaml:"cache"`
+	Tracing     Tracing  `yaml:"tracing"`
+	Redirect    Redirect `yaml:"redirect"`
+	QR          QR       `yaml:"qr"`
+	GeoIP       GeoIP    `yaml:"geoip"`
+	Stats       Stats    `yaml:"stats"`
+}
+
+// редиректы: default_status используется для ссылок без своего кода,
+// постоянные редиректы (301, 308) браузер кэширует на permanent_max_age
+type Redirect struct {
+	DefaultStatus   int              `yaml:"default_status" env-default:"302"`
+	PermanentMaxAge time.Duration    `yaml:"permanent_max_age" env-default:"24h"`
+	Password        RedirectPassword `yaml:"password"`
+	// куда отправлять до начала работы ссылки (active_from), пусто - отвечать 404
+	ComingSoonURL string `yaml:"coming_soon_url"`
+	// куда отправлять, если алиаса нет или ссылку открыть нельзя, пусто - отвечать ошибкой
+	FallbackURL string `yaml:"fallback_url"`
+	// html шаблон страницы для браузера вместо ошибки, пусто - встроенная страница
+	NotFoundPage string `yaml:"not_found_page"`
+	// сколько посетитель видит один и тот же вариант a/b теста
+	VariantCookieTTL time.Duration `yaml:"variant_cookie_ttl" env-default:"720h"`
+}
+
+// qr коды ссылок: base_url - адрес сервиса для ссылок из общего пространства (пусто - из запроса),
+// max_size - наибольший размер картинки в пикселях, cache_size - сколько готовых картинок хранить, 0 - не кэшировать
+type QR struct {
+	BaseURL   string `yaml:"base_url"`
+	MaxSize   int    `yaml:"max_size" env-default:"2048"`
+	CacheSize int    `yaml:"cache_size" env-default:"1000"`
+}
+
+// страна посетителя для адресов ссылки с условием countries
+// database_path - файл базы в формате MaxMind (mmdb), пусто - страна не определяется
+// trusted_proxies - адреса и подсети прокси, от которых принимаем X-Forwarded-For
+type GeoIP struct {
+	DatabasePath   string   `yaml:"database_path" env:"GEOIP_DATABASE_PATH"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// статистика переходов: раз в rollup_interval переходы старше raw_retention сворачиваются в дневные агрегаты,
+// после этого по ним остаются только счётчики за день, raw_retention = 0 - хранить все переходы
+type Stats struct {
+	RawRetention   time.Duration `yaml:"raw_retention" env-default:"0s"`
+	RollupInterval time.Duration `yaml:"rollup_interval" env-default:"1h"`
+}
+
+// ссылки с паролем: после верного пароля выдаётся cookie, подписанная cookie_secret,
+// если секрет не задан - генерируется при запуске (cookie перестают действовать после перезапуска)
+// ввод пароля ограничен max_attempts попытками на ссылку за attempt_window
+type RedirectPassword struct {
+	CookieSecret  string        `yaml:"cookie_secret" env:"REDIRECT_COOKIE_SECRET"`
+	CookieTTL     time.Duration `yaml:"cookie_ttl" env-default:"12h"`
+	MaxAttempts   int           `yaml:"max_attempts" env-default:"5"`
+	AttemptWindow time.Duration `yaml:"attempt_window" env-default:"15m"`
+}
+
+// трейсинг: exporter - none, stdout или otlp (http коллектор по адресу otlp_endpoint)
+type Tracing struct {
+	Exporter     string  `yaml:"exporter" env-default:"none"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env-default:"localhost:4318"`
+	SampleRatio  float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// настройки пула соединений с БД
+type Storage struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"10"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env-default:"0s"`
+	BusyTimeout     time.Duration `yaml:"busy_timeout" env-default:"5s"`
 }
 
 // добавили user и  password
@@ -23,6 +94,30 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 	User        string        `yaml:"user" env-required:"true"`
 	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// при остановке readyz сначала отвечает 503 в течение drain_delay, потом сервер дорабатывает запросы shutdown_timeout
+	DrainDelay      time.Duration `yaml:"drain_delay" env-default:"5s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	TLS             TLS           `yaml:"tls"`
+}
+
+// https на встроенном сервере, сертификат перечитывается при изменении файлов
+// раз в reload_interval, reload_interval = 0 - не перечитывать
+type TLS struct {
+	Enabled        bool          `yaml:"enabled" env-default:"false"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	MinVersion     string        `yaml:"min_version" env-default:"1.2"`
+	CipherSuites   []string      `yaml:"cipher_suites"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"30s"`
+	// адрес для http сервера, который перенаправляет всё на https, пусто - не запускать
+	RedirectAddress string `yaml:"redirect_address"`
+}
+
+// кэш для редиректов, size = 0 отключает кэш
+type Cache struct {
+	Size        int           `yaml:"size" env-default:"10000"`
+	TTL         time.Duration `yaml:"ttl" env-default:"5m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"30s"`
 }
 
 // функция читает файл с конфигом и заполнит объект Config
@@ -45,5 +140,10 @@ func MustLoad() *Config {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	// интервал фонового воркера уходит в time.NewTicker, который не принимает 0
+	if cfg.Stats.RawRetention > 0 && cfg.Stats.RollupInterval <= 0 {
+		log.Fatalf("stats.rollup_interval must be positive, got %s", cfg.Stats.RollupInterval)
+	}
+
 	return &cfg
 }
@@ -1,13 +1,16 @@
 package delete
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 
+	"url-shortener/internal/lib/hostname"
 	resp "url-shortener/internal/lib/logger/api/response"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
@@ -15,19 +18,24 @@ import (
 	"github.com/go-chi/render"
 )
 
+var tracer = tracing.Tracer("handlers/delete")
+
 // интерфейс для удаления url
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLDeleter
 type URLDeleter interface {
-	DeleteURL(alias string) error
-	GetURL(alias string) (string, error)
+	DeleteURL(ctx context.Context, domain string, alias string) error
 }
 
-// возвращает обработчик который удаляет url
+// возвращает обработчик который удаляет url, домен алиаса передаётся в ?domain=
 func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.deleter.New"
 
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
 		log = log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
 
 		// получаем параметр alias из роутера, т.е. /{alias}
@@ -35,35 +43,26 @@ func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 		fmt.Print(alias)
 		// если алиас пришёл пустым
 		if alias == "" {
-			log.Info("alias not empty")
+			log.InfoContext(r.Context(), "alias not empty")
 
 			render.JSON(w, r, resp.Error("invalid request"))
 
 			return
 		}
 
-		// если алиас не пустой, то получаем url
-		resURL, err := urlDeleter.GetURL(alias)
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		err := urlDeleter.DeleteURL(r.Context(), domain, alias)
 		// если алиас не найден
 		if errors.Is(err, storage.ErrURLNotFound) {
-			log.Info("url not found", "alias", alias)
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
 
-			render.JSON(w, r, resp.Error("not found"))
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
 
 			return
 		}
-		// обрабатываем общую ошибку
-		if err != nil {
-			log.Error("failed to find url", sl.Err(err))
-
-			render.JSON(w, r, resp.Error("internal error"))
-
-			return
-		}
-
-		err = urlDeleter.DeleteURL(alias)
 		if err != nil {
-			log.Error("failed to delete url", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to delete url", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("failed to delete url"))
 
@@ -71,8 +70,7 @@ func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 		}
 
 		// сообщаем что url удален
-		log.Info("successfully delete url", slog.String("url", resURL))
-		render.JSON(w, r, "successfully delete url")
-		// http.Redirect(w, r, resURL, http.StatusFound)
+		log.InfoContext(r.Context(), "successfully delete url", slog.String("alias", alias), slog.String("domain", domain))
+		render.JSON(w, r, resp.OK())
 	}
 }
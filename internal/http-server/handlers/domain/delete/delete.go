@@ -0,0 +1,82 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/domain/delete")
+
+// интерфейс для удаления домена
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=DomainDeleter
+type DomainDeleter interface {
+	DeleteDomain(ctx context.Context, host string) error
+}
+
+// возвращает обработчик который удаляет домен, домен с ссылками удалить нельзя
+func New(log *slog.Logger, domainDeleter DomainDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.delete.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		// middleware.URLFormat считает последнюю часть хоста расширением (.com) и отрезает её, возвращаем
+		host := chi.URLParam(r, "host")
+		if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format != "" {
+			host += "." + format
+		}
+		host = hostname.Normalize(host)
+		if host == "" {
+			log.InfoContext(r.Context(), "host is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		err := domainDeleter.DeleteDomain(r.Context(), host)
+		if errors.Is(err, storage.ErrDomainNotFound) {
+			log.InfoContext(r.Context(), "domain not found", slog.String("host", host))
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeDomainNotFound, "domain not found"))
+
+			return
+		}
+		// сначала нужно удалить ссылки домена
+		if errors.Is(err, storage.ErrDomainInUse) {
+			log.InfoContext(r.Context(), "domain has urls", slog.String("host", host))
+
+			render.JSON(w, r, resp.Error("domain has urls"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to delete domain", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "domain deleted", slog.String("host", host))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
@@ -0,0 +1,59 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/domain/list")
+
+// ответ со списком зарегистрированных доменов
+type Response struct {
+	resp.Response
+	Domains []storage.Domain `json:"domains"`
+}
+
+// интерфейс для получения списка доменов
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=DomainLister
+type DomainLister interface {
+	ListDomains(ctx context.Context) ([]storage.Domain, error)
+}
+
+// возвращает обработчик который отдаёт список зарегистрированных доменов
+func New(log *slog.Logger, domainLister DomainLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.list.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		domains, err := domainLister.ListDomains(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list domains", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "got domains", slog.Int("count", len(domains)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Domains:  domains,
+		})
+	}
+}
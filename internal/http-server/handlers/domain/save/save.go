@@ -0,0 +1,90 @@
+package save
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+var tracer = tracing.Tracer("handlers/domain/save")
+
+// домен, который нужно зарегистрировать (например, go.example.com)
+type Request struct {
+	Host string `json:"host" validate:"required,hostname"`
+}
+
+// интерфейс для регистрации домена
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=DomainSaver
+type DomainSaver interface {
+	SaveDomain(ctx context.Context, host string) error
+}
+
+// возвращает обработчик который регистрирует домен для коротких ссылок
+func New(log *slog.Logger, domainSaver DomainSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.save.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && err != io.EOF {
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		req.Host = hostname.Normalize(req.Host)
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.ErrorContext(r.Context(), "invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		err = domainSaver.SaveDomain(r.Context(), req.Host)
+		// домен уже зарегистрирован
+		if errors.Is(err, storage.ErrDomainExists) {
+			log.InfoContext(r.Context(), "domain already exists", slog.String("host", req.Host))
+
+			render.JSON(w, r, resp.Error("domain already exists"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to add domain", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to add domain"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "domain added", slog.String("host", req.Host))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
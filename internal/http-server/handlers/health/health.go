@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/lib/health"
+	resp "url-shortener/internal/lib/logger/api/response"
+
+	"github.com/go-chi/render"
+)
+
+// ответ проверки готовности, в checks результат по каждой проверке
+type Response struct {
+	resp.Response
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+const (
+	checkOK = "ok"
+
+	checkTimeout = 2 * time.Second
+)
+
+// GET /healthz - процесс жив и отвечает
+func NewLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+// GET /readyz - сервис готов принимать трафик
+// отдаёт 503, если хотя бы одна проверка не прошла или сервис останавливается
+func NewReadiness(log *slog.Logger, checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.NewReadiness"
+
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		res := Response{Response: resp.OK(), Checks: map[string]string{}}
+
+		for _, result := range checker.Run(ctx) {
+			if result.Err != nil {
+				log.WarnContext(ctx, "readiness check failed", slog.String("op", op), slog.String("check", result.Name), slog.String("error", result.Err.Error()))
+
+				res.Checks[result.Name] = result.Err.Error()
+				res.Response = resp.Error("not ready")
+
+				continue
+			}
+			res.Checks[result.Name] = checkOK
+		}
+
+		if checker.Draining() {
+			res.Checks["shutdown"] = "draining"
+			res.Response = resp.Error("shutting down")
+		}
+
+		if res.Status != resp.StatusOK {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+
+		render.JSON(w, r, res)
+	}
+}
@@ -0,0 +1,65 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	handler "url-shortener/internal/http-server/handlers/health"
+	"url-shortener/internal/lib/health"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestReadiness(t *testing.T) {
+	cases := []struct {
+		name       string
+		storageErr error
+		draining   bool
+		wantCode   int
+		wantChecks map[string]string
+	}{
+		{
+			name:       "Ready",
+			wantCode:   http.StatusOK,
+			wantChecks: map[string]string{"storage": "ok"},
+		},
+		{
+			name:       "Storage unavailable",
+			storageErr: errors.New("database is closed"),
+			wantCode:   http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"storage": "database is closed"},
+		},
+		{
+			name:       "Draining",
+			draining:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"storage": "ok", "shutdown": "draining"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := health.NewChecker()
+			checker.Add("storage", func(context.Context) error { return tc.storageErr })
+			if tc.draining {
+				checker.SetDraining()
+			}
+
+			rr := httptest.NewRecorder()
+			handler.NewReadiness(slogdiscard.NewDiscardLogger(), checker).
+				ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tc.wantCode, rr.Code)
+
+			var res handler.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantChecks, res.Checks)
+		})
+	}
+}
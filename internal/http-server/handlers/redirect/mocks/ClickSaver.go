@@ -0,0 +1,48 @@
+// Code generated by mockery v2.44.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// ClickSaver is an autogenerated mock type for the ClickSaver type
+type ClickSaver struct {
+	mock.Mock
+}
+
+// SaveClick provides a mock function with given fields: ctx, c
+func (_m *ClickSaver) SaveClick(ctx context.Context, c storage.Click) error {
+	ret := _m.Called(ctx, c)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveClick")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Click) error); ok {
+		r0 = rf(ctx, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewClickSaver creates a new instance of ClickSaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClickSaver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClickSaver {
+	mock := &ClickSaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
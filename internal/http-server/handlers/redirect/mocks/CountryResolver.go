@@ -0,0 +1,56 @@
+// Code generated by mockery v2.44.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	netip "net/netip"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// CountryResolver is an autogenerated mock type for the CountryResolver type
+type CountryResolver struct {
+	mock.Mock
+}
+
+// Country provides a mock function with given fields: ip
+func (_m *CountryResolver) Country(ip netip.Addr) (string, error) {
+	ret := _m.Called(ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Country")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(netip.Addr) (string, error)); ok {
+		return rf(ip)
+	}
+	if rf, ok := ret.Get(0).(func(netip.Addr) string); ok {
+		r0 = rf(ip)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(netip.Addr) error); ok {
+		r1 = rf(ip)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewCountryResolver creates a new instance of CountryResolver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewCountryResolver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *CountryResolver {
+	mock := &CountryResolver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,58 @@
+// Code generated by mockery v2.44.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// StatsGetter is an autogenerated mock type for the StatsGetter type
+type StatsGetter struct {
+	mock.Mock
+}
+
+// GetStats provides a mock function with given fields: ctx, domain, alias, q
+func (_m *StatsGetter) GetStats(ctx context.Context, domain string, alias string, q storage.StatsQuery) (storage.Stats, error) {
+	ret := _m.Called(ctx, domain, alias, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStats")
+	}
+
+	var r0 storage.Stats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, storage.StatsQuery) (storage.Stats, error)); ok {
+		return rf(ctx, domain, alias, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, storage.StatsQuery) storage.Stats); ok {
+		r0 = rf(ctx, domain, alias, q)
+	} else {
+		r0 = ret.Get(0).(storage.Stats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, storage.StatsQuery) error); ok {
+		r1 = rf(ctx, domain, alias, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewStatsGetter creates a new instance of StatsGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewStatsGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *StatsGetter {
+	mock := &StatsGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
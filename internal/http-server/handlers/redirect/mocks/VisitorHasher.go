@@ -0,0 +1,59 @@
+// Code generated by mockery v2.44.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	netip "net/netip"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// VisitorHasher is an autogenerated mock type for the VisitorHasher type
+type VisitorHasher struct {
+	mock.Mock
+}
+
+// Hash provides a mock function with given fields: ctx, ip, userAgent, now
+func (_m *VisitorHasher) Hash(ctx context.Context, ip netip.Addr, userAgent string, now time.Time) (uint64, error) {
+	ret := _m.Called(ctx, ip, userAgent, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Hash")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, netip.Addr, string, time.Time) (uint64, error)); ok {
+		return rf(ctx, ip, userAgent, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, netip.Addr, string, time.Time) uint64); ok {
+		r0 = rf(ctx, ip, userAgent, now)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, netip.Addr, string, time.Time) error); ok {
+		r1 = rf(ctx, ip, userAgent, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewVisitorHasher creates a new instance of VisitorHasher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewVisitorHasher(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *VisitorHasher {
+	mock := &VisitorHasher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
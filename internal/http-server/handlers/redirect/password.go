@@ -0,0 +1,128 @@
+package redirect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// страница с вводом пароля для защищённой ссылки
+var passwordPage = template.Must(template.New("password").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is password protected.</p>
+{{if .}}<p style="color:#b00020">{{.}}</p>{{end}}
+<input type="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// максимальный размер формы с паролем
+const maxPasswordForm = 4 << 10
+
+// cookie выдаётся на ссылку и перестаёт действовать, если пароль поменяли
+func passwordCookieName(u storage.URL) string {
+	return "url_shortener_pass_" + strconv.FormatInt(u.ID, 10)
+}
+
+func passwordCookieValue(u storage.URL) string {
+	sum := sha256.Sum256([]byte(u.PasswordHash))
+
+	return strconv.FormatInt(u.ID, 10) + "-" + hex.EncodeToString(sum[:8])
+}
+
+// проверяем подписанную cookie, которую выдали после ввода пароля
+func passwordAccepted(r *http.Request, u storage.URL, opts Options) bool {
+	c, err := r.Cookie(passwordCookieName(u))
+	if err != nil {
+		return false
+	}
+
+	value, ok := opts.Signer.Verify(c.Value, time.Now())
+
+	return ok && value == passwordCookieValue(u)
+}
+
+// показываем форму или проверяем присланный пароль
+// после верного пароля ставим cookie и отправляем браузер на тот же адрес, переход обработается как обычно
+func handlePassword(w http.ResponseWriter, r *http.Request, log *slog.Logger, u storage.URL, opts Options) {
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if r.Method != http.MethodPost {
+		metrics.Redirects.WithLabelValues("password_required").Inc()
+		renderPasswordPage(w, log, http.StatusOK, "")
+
+		return
+	}
+
+	// попытки ограничиваем на ссылку, чтобы пароль нельзя было подобрать перебором
+	key := strconv.FormatInt(u.ID, 10)
+	if ok, retryAfter := opts.PasswordLimiter.Allow(key); !ok {
+		log.InfoContext(r.Context(), "too many password attempts", slog.String("alias", u.Alias))
+		metrics.Redirects.WithLabelValues("password_required").Inc()
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		renderPasswordPage(w, log, http.StatusTooManyRequests, "Too many attempts, try again later.")
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordForm)
+	password := r.PostFormValue("password")
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		log.InfoContext(r.Context(), "wrong password", slog.String("alias", u.Alias))
+		metrics.Redirects.WithLabelValues("password_required").Inc()
+
+		renderPasswordPage(w, log, http.StatusUnauthorized, "Wrong password.")
+
+		return
+	}
+
+	opts.PasswordLimiter.Reset(key)
+
+	// имя cookie своё у каждой ссылки, поэтому путь - весь сайт: так cookie видят и редирект,
+	// и страница просмотра /{alias}+
+	expires := time.Now().Add(opts.PasswordCookieTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     passwordCookieName(u),
+		Value:    opts.Signer.Sign(passwordCookieValue(u), expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.InfoContext(r.Context(), "password accepted", slog.String("alias", u.Alias))
+
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
+
+func renderPasswordPage(w http.ResponseWriter, log *slog.Logger, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := passwordPage.Execute(w, message); err != nil {
+		log.Error("failed to render password page", sl.Err(err))
+	}
+}
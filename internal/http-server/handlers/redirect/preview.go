@@ -0,0 +1,165 @@
+package redirect
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/utm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// интерфейс для получения числа переходов по ссылке
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=StatsGetter
+type StatsGetter interface {
+	GetStats(ctx context.Context, domain string, alias string, q storage.StatsQuery) (storage.Stats, error)
+}
+
+// данные страницы просмотра
+// URL пустой, если ссылка с паролем и пароль ещё не введён, Clicks nil - число переходов получить не удалось
+type previewData struct {
+	ShortURL  string
+	URL       string
+	Title     string
+	CreatedAt *time.Time
+	Clicks    *int64
+	Protected bool
+	State     string
+}
+
+// страница просмотра: куда ведёт ссылка, без перехода по ней
+var previewPage = template.Must(template.New("preview").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<main>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+<p>{{.ShortURL}} leads to:</p>
+{{if .URL}}<p><a href="{{.URL}}" rel="nofollow noopener">{{.URL}}</a></p>
+{{else if .Protected}}<p>The destination is hidden, this link is password protected.</p>
+{{end}}
+{{if .State}}<p><strong>{{.State}}</strong></p>
+{{end}}
+<dl>
+{{if .CreatedAt}}<dt>Created</dt><dd>{{.CreatedAt.Format "2006-01-02"}}</dd>
+{{end}}
+{{if .Clicks}}<dt>Clicks</dt><dd>{{.Clicks}}</dd>
+{{end}}
+</dl>
+</main>
+</body>
+</html>
+`))
+
+// возвращает обработчик страницы просмотра ссылки (/{alias}+ или /{alias}?preview=1)
+// ссылка ищется так же, как при редиректе, переход не засчитывается
+func NewPreview(log *slog.Logger, urlGetter URLGetter, statsGetter StatsGetter, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.redirect.NewPreview"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		host := hostname.Normalize(r.Host)
+
+		u, err := urlGetter.GetURL(r.Context(), host, alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "host", host)
+			metrics.Redirects.WithLabelValues("not_found").Inc()
+
+			unavailable(w, r, log, opts, http.StatusNotFound, "not found")
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get url", sl.Err(err))
+			metrics.Redirects.WithLabelValues("error").Inc()
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+		now := time.Now()
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		data := previewData{
+			ShortURL:  scheme + "://" + r.Host + "/" + alias,
+			URL:       u.URL,
+			Title:     u.Title,
+			CreatedAt: u.CreatedAt,
+			Protected: u.PasswordHash != "",
+		}
+
+		// адрес ссылки с паролем показываем только тому, кто пароль уже ввёл
+		if data.Protected && !passwordAccepted(r, u, opts) {
+			data.URL = ""
+		}
+
+		if data.URL != "" && u.UTMTemplate != "" {
+			if expanded, err := utm.Expand(data.URL, utm.Vars{Alias: alias, Host: host, Now: now}); err == nil {
+				data.URL = expanded
+			}
+		}
+
+		switch {
+		case u.NotActiveYet(now):
+			data.State = "This link is not active yet."
+		case u.Expired(now):
+			data.State = "This link has expired."
+		case u.RemainingClicks != nil && *u.RemainingClicks == 0:
+			data.State = "This link has been used up."
+		}
+
+		// без числа переходов страница всё равно полезна, поэтому ошибку только логируем
+		stats, err := statsGetter.GetStats(r.Context(), u.Domain, u.Alias, storage.StatsQuery{})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get stats", sl.Err(err))
+		} else {
+			data.Clicks = &stats.Clicks
+		}
+
+		log.InfoContext(r.Context(), "preview", slog.String("alias", alias))
+		metrics.Redirects.WithLabelValues("preview").Inc()
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		if err := previewPage.Execute(w, data); err != nil {
+			log.ErrorContext(r.Context(), "failed to render preview page", sl.Err(err))
+		}
+	}
+}
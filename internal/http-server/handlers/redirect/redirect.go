@@ -1,12 +1,30 @@
 package redirect
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"html/template"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
+	"url-shortener/internal/lib/botdetect"
+	"url-shortener/internal/lib/clientip"
+	"url-shortener/internal/lib/hostname"
 	resp "url-shortener/internal/lib/logger/api/response"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/ratelimit"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/lib/useragent"
+	"url-shortener/internal/lib/utm"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
@@ -14,53 +32,392 @@ import (
 	"github.com/go-chi/render"
 )
 
+var tracer = tracing.Tracer("handlers/redirect")
+
 // интерфейс для получения url по алиасу
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLGetter
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetURL(ctx context.Context, host string, alias string) (storage.URL, error)
+}
+
+// интерфейс для сохранения перехода по алиасу (для статистики)
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=ClickSaver
+type ClickSaver interface {
+	SaveClick(ctx context.Context, c storage.Click) error
+}
+
+// интерфейс для определения страны по ip (ISO код, пусто - неизвестно)
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=CountryResolver
+type CountryResolver interface {
+	Country(ip netip.Addr) (string, error)
+}
+
+// интерфейс для хэша посетителя, по которому считаются уникальные посетители
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=VisitorHasher
+type VisitorHasher interface {
+	Hash(ctx context.Context, ip netip.Addr, userAgent string, now time.Time) (uint64, error)
+}
+
+// настройки редиректа
+type Options struct {
+	// код ответа для ссылок, у которых не указан свой
+	DefaultStatus int
+	// сколько браузеры и прокси кэшируют постоянный редирект
+	PermanentMaxAge time.Duration
+
+	// куда отправлять до начала работы ссылки (active_from), пусто - отвечать 404
+	ComingSoonURL string
+
+	// куда отправлять, если ссылку открыть нельзя и у неё нет своего запасного адреса,
+	// пусто - отвечать ошибкой (браузеру html страницей NotFoundPage)
+	FallbackURL  string
+	NotFoundPage *template.Template
+
+	// ссылки с паролем: подпись cookie, которая выдаётся после ввода пароля, её срок действия
+	// и ограничение попыток ввода пароля на ссылку
+	Signer            *signer.Signer
+	PasswordCookieTTL time.Duration
+	PasswordLimiter   *ratelimit.Limiter
+
+	// страна посетителя для адресов с условием countries: ip клиента с учётом доверенных прокси и база geoip
+	// GeoIP nil - страна неизвестна, такие адреса не выбираются
+	ClientIP *clientip.Resolver
+	GeoIP    CountryResolver
+
+	// сколько посетитель видит один и тот же вариант a/b теста
+	VariantCookieTTL time.Duration
+
+	// хэш посетителя для подсчёта уникальных, nil - уникальные не считаются
+	Visitors VisitorHasher
+
+	// страница просмотра для /{alias}?preview=1 (NewPreview), nil - параметр preview не обрабатывается
+	Preview http.HandlerFunc
+}
+
+// коды, которыми можно отвечать на переход по алиасу
+// 301 и 308 - постоянные (их кэширует браузер), 307 и 308 сохраняют метод и тело запроса
+func ValidStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
 }
 
 // возвращает обработчик который возвращает url (GetURL)
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+func New(log *slog.Logger, urlGetter URLGetter, clickSaver ClickSaver, opts Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.redirect.New"
 
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
 		log = log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
 
 		// получаем параметр alias из роутера, т.е. /{alias}
 		alias := chi.URLParam(r, "alias")
 		// если алиас пришёл пустым
 		if alias == "" {
-			log.Info("alias not empty")
+			log.InfoContext(r.Context(), "alias not empty")
 
 			render.JSON(w, r, resp.Error("invalid request"))
 
 			return
 		}
 
+		// вместо перехода показываем, куда ведёт ссылка
+		if opts.Preview != nil && r.URL.Query().Get("preview") == "1" {
+			opts.Preview(w, r)
+
+			return
+		}
+
+		// путь после алиаса для /{alias}/*, расширение последней части отрезает middleware.URLFormat - возвращаем
+		rest := chi.URLParam(r, "*")
+		if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); rest != "" && format != "" {
+			rest += "." + format
+		}
+
+		// алиасы ищутся в домене, на который пришёл запрос
+		host := hostname.Normalize(r.Host)
+
 		// если алиас не пустой, то получаем url
-		resURL, err := urlGetter.GetURL(alias)
+		u, err := urlGetter.GetURL(r.Context(), host, alias)
 		// если алиас не найден
 		if errors.Is(err, storage.ErrURLNotFound) {
-			log.Info("url not found", "alias", alias)
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "host", host)
+			metrics.Redirects.WithLabelValues("not_found").Inc()
 
-			render.JSON(w, r, resp.Error("not found"))
+			unavailable(w, r, log, opts, http.StatusNotFound, "not found")
 
 			return
 		}
 		// обрабатываем общую ошибку
 		if err != nil {
-			log.Error("failed to get url", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to get url", sl.Err(err))
+			metrics.Redirects.WithLabelValues("error").Inc()
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+		now := time.Now()
+
+		// ссылка ещё не начала работать
+		if u.NotActiveYet(now) {
+			log.InfoContext(r.Context(), "url not active yet", "alias", alias, "active_from", u.ActiveFrom)
+			metrics.Redirects.WithLabelValues("not_active").Inc()
+
+			unavailable(w, r, log, opts, http.StatusNotFound, "not found", opts.ComingSoonURL, u.FallbackURL)
+
+			return
+		}
+
+		// срок действия ссылки истёк
+		if u.Expired(now) {
+			log.InfoContext(r.Context(), "url expired", "alias", alias, "expires_at", u.ExpiresAt)
+			metrics.Redirects.WithLabelValues("expired").Inc()
+
+			unavailable(w, r, log, opts, http.StatusGone, "link expired", u.FallbackURL)
+
+			return
+		}
+
+		// путь после алиаса принимаем, только если ссылка это разрешает
+		if rest != "" && !u.ForwardPath {
+			log.InfoContext(r.Context(), "path forwarding disabled", "alias", alias, "path", rest)
+			metrics.Redirects.WithLabelValues("not_found").Inc()
+
+			unavailable(w, r, log, opts, http.StatusNotFound, "not found")
+
+			return
+		}
+
+		// для ссылки с паролем вместо редиректа показываем форму, пока пароль не введён
+		if u.PasswordHash != "" && !passwordAccepted(r, u, opts) {
+			handlePassword(w, r, log, u, opts)
+
+			return
+		}
+
+		// боты (превью в мессенджерах, краулеры, проверки доступности) переходят как обычно,
+		// но их переходы помечаются, чтобы не смешивать со статистикой людей
+		bot := botdetect.IsBot(r)
+
+		// у ссылки с ограничением переход расходуется, поэтому превью в мессенджерах и HEAD получают
+		// страницу без адреса и не тратят переходы, иначе одноразовая ссылка сгорит до того, как её откроет человек
+		// curl, wget и скрипты переходят как обычно: ими ссылку открывают намеренно
+		if u.MaxClicks > 0 && botdetect.IsUnfurl(r) {
+			log.InfoContext(r.Context(), "unfurl of limited url", "alias", alias)
+			metrics.Redirects.WithLabelValues("unfurl").Inc()
+
+			handleUnfurl(w, r, log)
+
+			return
+		}
+
+		// адрес для системы, устройства и страны посетителя, если ни один не подошёл - основной url
+		geo := slices.ContainsFunc(u.Targets, func(t storage.Target) bool { return len(t.Countries) > 0 })
+		matched := false
+		if len(u.Targets) > 0 {
+			// ответ зависит от User-Agent, кэши не должны отдавать его другим устройствам
+			w.Header().Add("Vary", "User-Agent")
+
+			var target string
+			if target, matched = selectTarget(u.Targets, visitor(r, log, opts, geo, bot)); matched {
+				u.URL = target
+			}
+		}
+
+		// остальные посетители распределяются между вариантами a/b теста
+		var variant string
+		if !matched && len(u.Variants) > 0 {
+			v := chooseVariant(w, r, u, opts)
+			u.URL, variant = v.URL, v.Name
+		}
+
+		// подстановки в utm параметрах ({date} и т.д.) раскрываются в момент перехода
+		if u.UTMTemplate != "" {
+			u.URL, err = utm.Expand(u.URL, utm.Vars{Alias: alias, Host: host, Now: now})
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to expand utm parameters", sl.Err(err))
+				metrics.Redirects.WithLabelValues("error").Inc()
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+		}
+
+		target, err := targetURL(u, rest, r.URL.Query())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to build target url", sl.Err(err))
+			metrics.Redirects.WithLabelValues("error").Inc()
 
 			render.JSON(w, r, resp.Error("internal error"))
 
 			return
 		}
+
+		click := storage.Click{
+			URLID:    u.ID,
+			Variant:  variant,
+			Bot:      bot,
+			Referrer: referrerDomain(r),
+			Source:   utmSource(r, target),
+			Browser:  useragent.Parse(r.UserAgent()).Browser,
+		}
+		if opts.Visitors != nil && !bot {
+			// без хэша переход всё равно засчитывается, только не попадает в уникальные
+			click.Visitor, err = opts.Visitors.Hash(r.Context(), opts.ClientIP.IP(r), r.UserAgent(), now)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to hash visitor", sl.Err(err))
+			}
+		}
+
+		// сохранение перехода заодно расходует переход у ссылки с ограничением
+		err = clickSaver.SaveClick(r.Context(), click)
+		if errors.Is(err, storage.ErrURLExhausted) {
+			log.InfoContext(r.Context(), "url exhausted", "alias", alias)
+			metrics.Redirects.WithLabelValues("exhausted").Inc()
+
+			unavailable(w, r, log, opts, http.StatusGone, "link exhausted", u.FallbackURL)
+
+			return
+		}
+		if err != nil {
+			// без ограничения ошибка статистики не должна мешать редиректу, поэтому только логируем,
+			// а ссылку с ограничением без учёта перехода не открываем
+			log.ErrorContext(r.Context(), "failed to save click", sl.Err(err))
+
+			if u.MaxClicks > 0 {
+				metrics.Redirects.WithLabelValues("error").Inc()
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+		}
+
 		// сообщаем что url получен
-		log.Info("got url", slog.String("url", resURL))
+		log.InfoContext(r.Context(), "got url", slog.String("url", target), slog.Bool("bot", bot))
+		metrics.Redirects.WithLabelValues("found").Inc()
+
+		status := u.RedirectStatus
+		if status == 0 {
+			status = opts.DefaultStatus
+		}
+
+		// ответ по стране посетителя общие кэши хранить не должны
+		w.Header().Set("Cache-Control", cacheControl(status, opts.PermanentMaxAge, geo, limited(u)))
 
 		// redirect to found url
-		http.Redirect(w, r, resURL, http.StatusFound)
+		http.Redirect(w, r, target, status)
+	}
+}
+
+// посетитель по User-Agent, страну ищем в базе, только если она нужна условиям ссылки
+func visitor(r *http.Request, log *slog.Logger, opts Options, geo bool, bot bool) storage.Visitor {
+	ua := useragent.Parse(r.UserAgent())
+	v := storage.Visitor{OS: ua.OS, Device: ua.Device, Bot: bot}
+
+	if geo && opts.GeoIP != nil {
+		country, err := opts.GeoIP.Country(opts.ClientIP.IP(r))
+		if err != nil {
+			// без страны сработают остальные адреса или основной url
+			log.ErrorContext(r.Context(), "failed to resolve country", sl.Err(err))
+		}
+		v.Country = country
 	}
+
+	return v
+}
+
+// первый подходящий посетителю адрес ссылки
+func selectTarget(targets []storage.Target, v storage.Visitor) (string, bool) {
+	for _, t := range targets {
+		if t.Matches(v) {
+			return t.URL, true
+		}
+	}
+
+	return "", false
+}
+
+// итоговый url редиректа: путь после алиаса и параметры запроса, если ссылка их передаёт
+func targetURL(u storage.URL, rest string, query url.Values) (string, error) {
+	forwardPath := u.ForwardPath && rest != ""
+	forwardQuery := u.ForwardQuery && len(query) > 0
+	if !forwardPath && !forwardQuery {
+		return u.URL, nil
+	}
+
+	target, err := url.Parse(u.URL)
+	if err != nil {
+		return "", fmt.Errorf("parse %q: %w", u.URL, err)
+	}
+
+	if forwardPath {
+		// Clean от корня не даёт через ".." выйти выше пути целевого url
+		p := path.Clean("/" + rest)
+		if strings.HasSuffix(rest, "/") && p != "/" {
+			p += "/"
+		}
+		target = target.JoinPath(p)
+	}
+
+	if forwardQuery {
+		target.RawQuery = mergeQuery(target.Query(), query, u.QueryConflict).Encode()
+	}
+
+	return target.String(), nil
+}
+
+// добавляем параметры запроса к параметрам целевого url
+func mergeQuery(target url.Values, query url.Values, conflict string) url.Values {
+	for key, values := range query {
+		if _, ok := target[key]; !ok {
+			target[key] = values
+			continue
+		}
+
+		switch conflict {
+		case storage.QueryConflictOverride:
+			target[key] = values
+		case storage.QueryConflictAppend:
+			target[key] = append(target[key], values...)
+		default:
+			// storage.QueryConflictKeep: значение из целевого url важнее
+		}
+	}
+
+	return target
+}
+
+// постоянный редирект можно кэшировать, временный - нет, иначе ссылку нельзя поменять,
+// а повторные переходы не дойдут до сервиса и не попадут в статистику
+// private - ответ зависит от посетителя, общие кэши (cdn, прокси) не должны его хранить
+// limited - ссылка с ограничениями, которые проверяются при каждом переходе, такой ответ не кэшируется никогда
+func cacheControl(status int, permanentMaxAge time.Duration, private bool, limited bool) string {
+	if !limited && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+		scope := "public"
+		if private {
+			scope = "private"
+		}
+		return fmt.Sprintf("%s, max-age=%d", scope, int(permanentMaxAge.Seconds()))
+	}
+
+	return "private, no-store"
+}
+
+// у ссылки есть ограничения, которые проверяются при каждом переходе: число переходов, срок действия,
+// пароль или a/b тест, закэшированный редирект обошёл бы эти проверки
+func limited(u storage.URL) bool {
+	return u.MaxClicks > 0 || u.ExpiresAt != nil || u.ActiveFrom != nil || u.PasswordHash != "" || len(u.Variants) > 0
 }
@@ -1,19 +1,37 @@
 package redirect_test
 
 import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/lib/clientip"
 	"url-shortener/internal/lib/logger/api"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/ratelimit"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/storage"
 )
 
+var opts = redirect.Options{DefaultStatus: http.StatusFound, PermanentMaxAge: time.Hour}
+
+// User-Agent браузера, с Accept-Language такой запрос считается переходом человека
+const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
 func TestSaveHandler(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -32,14 +50,20 @@ func TestSaveHandler(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
 
 			if tc.respError == "" || tc.mockError != nil {
-				urlGetterMock.On("GetURL", tc.alias).
-					Return(tc.url, tc.mockError).Once()
+				// тестовый сервер слушает 127.0.0.1, порт в host не входит
+				urlGetterMock.On("GetURL", mock.Anything, "127.0.0.1", tc.alias).
+					Return(storage.URL{ID: 1, Alias: tc.alias, URL: tc.url}, tc.mockError).Once()
+			}
+			if tc.respError == "" {
+				clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).
+					Return(nil).Once()
 			}
 
 			r := chi.NewRouter()
-			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
 
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -52,3 +76,948 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name         string
+		method       string
+		status       int
+		link         storage.URL
+		wantStatus   int
+		cacheControl string
+	}{
+		{
+			name:         "Default",
+			method:       http.MethodGet,
+			wantStatus:   http.StatusFound,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "Permanent",
+			method:       http.MethodGet,
+			status:       http.StatusMovedPermanently,
+			wantStatus:   http.StatusMovedPermanently,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Temporary with method",
+			method:       http.MethodPost,
+			status:       http.StatusTemporaryRedirect,
+			wantStatus:   http.StatusTemporaryRedirect,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "Permanent with click limit",
+			method:       http.MethodGet,
+			status:       http.StatusMovedPermanently,
+			link:         storage.URL{MaxClicks: 10},
+			wantStatus:   http.StatusMovedPermanently,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "Permanent with expiry",
+			method:       http.MethodGet,
+			status:       http.StatusPermanentRedirect,
+			link:         storage.URL{ExpiresAt: &future},
+			wantStatus:   http.StatusPermanentRedirect,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "Permanent with active window",
+			method:       http.MethodGet,
+			status:       http.StatusMovedPermanently,
+			link:         storage.URL{ActiveFrom: &past},
+			wantStatus:   http.StatusMovedPermanently,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:   "Permanent with variants",
+			method: http.MethodGet,
+			status: http.StatusMovedPermanently,
+			link: storage.URL{Variants: []storage.Variant{
+				{Name: "a", URL: "https://web.telegram.org", Weight: 1},
+			}},
+			wantStatus:   http.StatusMovedPermanently,
+			cacheControl: "private, no-store",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			link := tc.link
+			link.ID, link.Alias, link.URL, link.RedirectStatus = 1, "tg", "https://web.telegram.org", tc.status
+			click := storage.Click{URLID: 1, Browser: "firefox"}
+			if len(link.Variants) > 0 {
+				click.Variant = link.Variants[0].Name
+			}
+
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").Return(link, nil).Once()
+			clickSaverMock.On("SaveClick", mock.Anything, click).Return(nil).Once()
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+			req := httptest.NewRequest(tc.method, "http://example.com/tg", nil)
+			req.Header.Set("User-Agent", firefox)
+			req.Header.Set("Accept-Language", "en")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			assert.Equal(t, "https://web.telegram.org", rr.Header().Get("Location"))
+			assert.Equal(t, tc.cacheControl, rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRedirectPassthrough(t *testing.T) {
+	cases := []struct {
+		name       string
+		link       storage.URL
+		path       string
+		wantURL    string
+		wantSource string
+		respError  string
+	}{
+		{
+			name:       "Query dropped",
+			link:       storage.URL{URL: "https://example.org/page?a=1"},
+			path:       "/tg?utm_source=x",
+			wantURL:    "https://example.org/page?a=1",
+			wantSource: "x",
+		},
+		{
+			name:       "Query merged",
+			link:       storage.URL{URL: "https://example.org/page?a=1", ForwardQuery: true},
+			path:       "/tg?utm_source=x&a=2",
+			wantURL:    "https://example.org/page?a=1&utm_source=x",
+			wantSource: "x",
+		},
+		{
+			name:    "Query override",
+			link:    storage.URL{URL: "https://example.org/page?a=1", ForwardQuery: true, QueryConflict: storage.QueryConflictOverride},
+			path:    "/tg?a=2",
+			wantURL: "https://example.org/page?a=2",
+		},
+		{
+			name:    "Query append",
+			link:    storage.URL{URL: "https://example.org/page?a=1", ForwardQuery: true, QueryConflict: storage.QueryConflictAppend},
+			path:    "/tg?a=2",
+			wantURL: "https://example.org/page?a=1&a=2",
+		},
+		{
+			name:    "Path appended",
+			link:    storage.URL{URL: "https://example.org/docs/", ForwardPath: true},
+			path:    "/tg/guide/intro/",
+			wantURL: "https://example.org/docs/guide/intro/",
+		},
+		{
+			name:    "Path cannot escape",
+			link:    storage.URL{URL: "https://example.org/docs", ForwardPath: true},
+			path:    "/tg/../../admin",
+			wantURL: "https://example.org/docs/admin",
+		},
+		{
+			name:      "Path forwarding disabled",
+			link:      storage.URL{URL: "https://example.org/docs"},
+			path:      "/tg/guide",
+			respError: "not found",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			tc.link.ID = 1
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").
+				Return(tc.link, nil).Once()
+			if tc.respError == "" {
+				clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true, Source: tc.wantSource}).
+					Return(nil).Once()
+			}
+
+			r := chi.NewRouter()
+			h := redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts)
+			r.HandleFunc("/{alias}", h)
+			r.HandleFunc("/{alias}/*", h)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tc.path, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if tc.respError != "" {
+				assert.Contains(t, rr.Body.String(), tc.respError)
+				return
+			}
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, tc.wantURL, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	// постоянный редирект ссылки с паролем всё равно не кэшируется, иначе браузер обойдёт проверку пароля
+	link := storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org", PasswordHash: string(hash), RedirectStatus: http.StatusMovedPermanently}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	clickSaverMock := mocks.NewClickSaver(t)
+
+	urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").Return(link, nil)
+	// переход засчитывается только после ввода пароля
+	clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).Return(nil).Once()
+
+	passwordOpts := opts
+	passwordOpts.Signer = signer.New([]byte("cookie secret"))
+	passwordOpts.PasswordCookieTTL = time.Hour
+	passwordOpts.PasswordLimiter = ratelimit.New(2, time.Minute, 10)
+
+	r := chi.NewRouter()
+	r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, passwordOpts))
+
+	submit := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/tg", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	// без пароля - форма
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com/tg", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `name="password"`)
+
+	rr = submit("wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// верный пароль - cookie и возврат на тот же адрес
+	rr = submit("secret")
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/tg", rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/tg", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMovedPermanently, rr.Code)
+	assert.Equal(t, "https://web.telegram.org", rr.Header().Get("Location"))
+	assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"))
+
+	// попытки ограничены
+	submit("wrong")
+	submit("wrong")
+	rr = submit("secret")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
+
+func TestRedirectExhausted(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	clickSaverMock := mocks.NewClickSaver(t)
+
+	urlGetterMock.On("GetURL", mock.Anything, "example.com", "once").
+		Return(storage.URL{ID: 1, Alias: "once", URL: "https://web.telegram.org", MaxClicks: 1}, nil).Once()
+	clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Browser: "firefox"}).
+		Return(storage.ErrURLExhausted).Once()
+
+	r := chi.NewRouter()
+	r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/once", nil)
+	req.Header.Set("User-Agent", firefox)
+	req.Header.Set("Accept-Language", "en")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Empty(t, rr.Header().Get("Location"))
+	assert.Contains(t, rr.Body.String(), "link exhausted")
+}
+
+func TestRedirectLimitedBot(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	clickSaverMock := mocks.NewClickSaver(t)
+
+	link := storage.URL{ID: 1, Alias: "once", URL: "https://web.telegram.org", MaxClicks: 1, FallbackURL: "https://example.org"}
+	urlGetterMock.On("GetURL", mock.Anything, "example.com", "once").Return(link, nil).Times(3)
+	// переход расходует только человек
+	clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Browser: "firefox"}).Return(nil).Once()
+
+	r := chi.NewRouter()
+	r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+	// превью в slack и HEAD получают страницу без адреса и не тратят переход
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req := httptest.NewRequest(method, "http://example.com/once", nil)
+		req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code, method)
+		assert.Empty(t, rr.Header().Get("Location"), method)
+		assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"), method)
+		assert.NotContains(t, rr.Body.String(), "web.telegram.org", method)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/once", nil)
+	req.Header.Set("User-Agent", firefox)
+	req.Header.Set("Accept-Language", "en")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://web.telegram.org", rr.Header().Get("Location"))
+}
+
+func TestRedirectLimitedDownload(t *testing.T) {
+	for _, ua := range []string{"curl/8.5.0", "Wget/1.21.4"} {
+		t.Run(ua, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			link := storage.URL{ID: 1, Alias: "file", URL: "https://files.example.org/report.pdf", MaxClicks: 3}
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "file").Return(link, nil).Once()
+			// утилиты считаются ботами в статистике, но переход расходуют как обычно
+			clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).Return(nil).Once()
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/file", nil)
+			req.Header.Set("User-Agent", ua)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, "https://files.example.org/report.pdf", rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectWindow(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name          string
+		link          storage.URL
+		comingSoonURL string
+		wantStatus    int
+		wantLocation  string
+	}{
+		{
+			name:       "Not active yet",
+			link:       storage.URL{ActiveFrom: &future},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:          "Coming soon page",
+			link:          storage.URL{ActiveFrom: &future},
+			comingSoonURL: "https://example.org/soon",
+			wantStatus:    http.StatusFound,
+			wantLocation:  "https://example.org/soon",
+		},
+		{
+			name:       "Expired",
+			link:       storage.URL{ActiveFrom: &past, ExpiresAt: &past},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name:         "Active",
+			link:         storage.URL{ActiveFrom: &past, ExpiresAt: &future},
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://web.telegram.org",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			tc.link.ID = 1
+			tc.link.URL = "https://web.telegram.org"
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").Return(tc.link, nil).Once()
+			if tc.wantLocation == tc.link.URL {
+				clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).Return(nil).Once()
+			}
+
+			windowOpts := opts
+			windowOpts.ComingSoonURL = tc.comingSoonURL
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, windowOpts))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com/tg", nil))
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			assert.Equal(t, tc.wantLocation, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectFallback(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name         string
+		alias        string
+		link         *storage.URL
+		fallbackURL  string
+		accept       string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:       "Not found json",
+			alias:      "missing",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"error":"not found"`,
+		},
+		{
+			name:       "Not found html",
+			alias:      "missing",
+			accept:     "text/html,application/xhtml+xml",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Link not found",
+		},
+		{
+			name:         "Global fallback",
+			alias:        "missing",
+			fallbackURL:  "https://example.org/",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://example.org/",
+		},
+		{
+			name:       "Expired html",
+			alias:      "tg",
+			link:       &storage.URL{ID: 1, URL: "https://web.telegram.org", ExpiresAt: &past},
+			accept:     "text/html",
+			wantStatus: http.StatusGone,
+			wantBody:   "This link is no longer available",
+		},
+		{
+			name:         "Link fallback overrides global",
+			alias:        "tg",
+			link:         &storage.URL{ID: 1, URL: "https://web.telegram.org", ExpiresAt: &past, FallbackURL: "https://example.org/sale-over"},
+			fallbackURL:  "https://example.org/",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://example.org/sale-over",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			if tc.link != nil {
+				urlGetterMock.On("GetURL", mock.Anything, "example.com", tc.alias).Return(*tc.link, nil).Once()
+			} else {
+				urlGetterMock.On("GetURL", mock.Anything, "example.com", tc.alias).Return(storage.URL{}, storage.ErrURLNotFound).Once()
+			}
+
+			fallbackOpts := opts
+			fallbackOpts.FallbackURL = tc.fallbackURL
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, fallbackOpts))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/"+tc.alias, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			assert.Equal(t, tc.wantLocation, rr.Header().Get("Location"))
+			assert.Contains(t, rr.Body.String(), tc.wantBody)
+			assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	created := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		path        string
+		link        storage.URL
+		wantBody    []string
+		notWantBody string
+	}{
+		{
+			name:     "Plus suffix",
+			path:     "/tg+",
+			link:     storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org", Title: "Telegram", CreatedAt: &created},
+			wantBody: []string{"https://web.telegram.org", "<title>Telegram</title>", "2024-03-05", "<dd>7</dd>"},
+		},
+		{
+			name:     "Preview parameter",
+			path:     "/tg?preview=1",
+			link:     storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org"},
+			wantBody: []string{"https://web.telegram.org", "<dd>7</dd>"},
+		},
+		{
+			name:        "Password hides destination",
+			path:        "/tg+",
+			link:        storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org", PasswordHash: "hash"},
+			wantBody:    []string{"password protected"},
+			notWantBody: "https://web.telegram.org",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+			statsGetterMock := mocks.NewStatsGetter(t)
+
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").Return(tc.link, nil).Once()
+			statsGetterMock.On("GetStats", mock.Anything, "", "tg", storage.StatsQuery{}).Return(storage.Stats{Alias: "tg", Clicks: 7}, nil).Once()
+
+			previewOpts := opts
+			previewOpts.Signer = signer.New([]byte("secret"))
+			previewOpts.Preview = redirect.NewPreview(slogdiscard.NewDiscardLogger(), urlGetterMock, statsGetterMock, previewOpts)
+
+			r := chi.NewRouter()
+			r.Get("/{alias}+", previewOpts.Preview)
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, previewOpts))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com"+tc.path, nil))
+
+			// переход не засчитывается, редиректа нет
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("Location"))
+			for _, want := range tc.wantBody {
+				assert.Contains(t, rr.Body.String(), want)
+			}
+			if tc.notWantBody != "" {
+				assert.NotContains(t, rr.Body.String(), tc.notWantBody)
+			}
+		})
+	}
+}
+
+func TestPreviewAfterUnlock(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	link := storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org", PasswordHash: string(hash)}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	statsGetterMock := mocks.NewStatsGetter(t)
+
+	urlGetterMock.On("GetURL", mock.Anything, mock.AnythingOfType("string"), "tg").Return(link, nil)
+	statsGetterMock.On("GetStats", mock.Anything, "", "tg", storage.StatsQuery{}).Return(storage.Stats{Alias: "tg"}, nil).Once()
+
+	previewOpts := opts
+	previewOpts.Signer = signer.New([]byte("cookie secret"))
+	previewOpts.PasswordCookieTTL = time.Hour
+	previewOpts.PasswordLimiter = ratelimit.New(5, time.Minute, 10)
+	previewOpts.Preview = redirect.NewPreview(slogdiscard.NewDiscardLogger(), urlGetterMock, statsGetterMock, previewOpts)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}+", previewOpts.Preview)
+	r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, mocks.NewClickSaver(t), previewOpts))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// cookie jar проверяет путь cookie так же, как браузер
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.PostForm(ts.URL+"/tg", url.Values{"password": {"secret"}})
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusSeeOther, res.StatusCode)
+
+	res, err = client.Get(ts.URL + "/tg+")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "https://web.telegram.org")
+}
+
+func TestRedirectTargets(t *testing.T) {
+	bot := true
+	link := storage.URL{
+		ID:    1,
+		Alias: "app",
+		URL:   "https://example.org",
+		Targets: []storage.Target{
+			{Bot: &bot, URL: "https://example.org/preview"},
+			{OS: "ios", URL: "https://apps.apple.com/app/id1"},
+			{OS: "android", Device: "mobile", URL: "https://play.google.com/store/apps/details?id=org.example"},
+		},
+	}
+
+	cases := []struct {
+		name         string
+		userAgent    string
+		wantLocation string
+		wantBrowser  string
+		wantBot      bool
+	}{
+		{
+			name:         "iOS",
+			userAgent:    "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			wantLocation: "https://apps.apple.com/app/id1",
+		},
+		{
+			name:         "Android phone",
+			userAgent:    "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			wantLocation: "https://play.google.com/store/apps/details?id=org.example",
+			wantBrowser:  "chrome",
+		},
+		{
+			name:         "Android tablet falls back to url",
+			userAgent:    "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			wantLocation: "https://example.org",
+			wantBrowser:  "chrome",
+		},
+		{
+			name:         "Bot",
+			userAgent:    "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			wantLocation: "https://example.org/preview",
+			wantBot:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "app").Return(link, nil).Once()
+			clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: tc.wantBot, Browser: tc.wantBrowser}).Return(nil).Once()
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/app", nil)
+			req.Header.Set("User-Agent", tc.userAgent)
+			req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, tc.wantLocation, rr.Header().Get("Location"))
+			assert.Equal(t, "User-Agent", rr.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestRedirectGeo(t *testing.T) {
+	link := storage.URL{
+		ID:             1,
+		Alias:          "sale",
+		URL:            "https://example.org",
+		RedirectStatus: http.StatusMovedPermanently,
+		Targets:        []storage.Target{{Countries: []string{"DE", "AT"}, URL: "https://example.de"}},
+	}
+
+	clientIP, err := clientip.New([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name         string
+		remoteAddr   string
+		ip           string
+		country      string
+		wantLocation string
+	}{
+		{
+			name:         "Country rule via trusted proxy",
+			remoteAddr:   "10.0.0.1:1234",
+			ip:           "198.51.100.1",
+			country:      "DE",
+			wantLocation: "https://example.de",
+		},
+		{
+			name:         "Other country falls back to url",
+			remoteAddr:   "203.0.113.9:1234",
+			ip:           "203.0.113.9",
+			country:      "US",
+			wantLocation: "https://example.org",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+			countryResolverMock := mocks.NewCountryResolver(t)
+
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "sale").Return(link, nil).Once()
+			clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).Return(nil).Once()
+			countryResolverMock.On("Country", netip.MustParseAddr(tc.ip)).Return(tc.country, nil).Once()
+
+			geoOpts := opts
+			geoOpts.ClientIP = clientIP
+			geoOpts.GeoIP = countryResolverMock
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, geoOpts))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/sale", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusMovedPermanently, rr.Code)
+			assert.Equal(t, tc.wantLocation, rr.Header().Get("Location"))
+			// ответ зависит от ip, общие кэши не должны его хранить
+			assert.Equal(t, "private, max-age=3600", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRedirectVariants(t *testing.T) {
+	// имя с пробелом, ';' и не ascii символами нельзя записать в cookie как есть
+	link := storage.URL{
+		ID:    1,
+		Alias: "ab",
+		URL:   "https://example.org",
+		Variants: []storage.Variant{
+			{Name: "a", URL: "https://example.org/a", Weight: 70},
+			{Name: "вариант Б; new", URL: "https://example.org/b", Weight: 30},
+		},
+	}
+	variants := map[string]string{"https://example.org/a": "a", "https://example.org/b": "вариант Б; new"}
+
+	newHandler := func(t *testing.T, variant string) http.Handler {
+		urlGetterMock := mocks.NewURLGetter(t)
+		clickSaverMock := mocks.NewClickSaver(t)
+
+		urlGetterMock.On("GetURL", mock.Anything, "example.com", "ab").Return(link, nil).Once()
+		// новому посетителю вариант выбирается случайно
+		var click any = mock.AnythingOfType("storage.Click")
+		if variant != "" {
+			click = storage.Click{URLID: 1, Variant: variant, Bot: true}
+		}
+		clickSaverMock.On("SaveClick", mock.Anything, click).Return(nil).Once()
+
+		variantOpts := opts
+		variantOpts.VariantCookieTTL = time.Hour
+
+		r := chi.NewRouter()
+		r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, variantOpts))
+
+		return r
+	}
+
+	// каждый вариант должен закрепляться, поэтому ходим новыми посетителями, пока не увидим оба
+	seen := make(map[string]bool)
+	for i := 0; i < 200 && len(seen) < len(variants); i++ {
+		rr := httptest.NewRecorder()
+		newHandler(t, "").ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com/ab", nil))
+
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "/ab", cookies[0].Path)
+		assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"))
+		// net/http отбрасывает недопустимые значения cookie, без значения вариант не закрепится
+		require.NotEmpty(t, cookies[0].Value)
+
+		location := rr.Header().Get("Location")
+		variant, ok := variants[location]
+		require.True(t, ok, location)
+		if seen[variant] {
+			continue
+		}
+		seen[variant] = true
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/ab", nil)
+		req.AddCookie(cookies[0])
+
+		rr = httptest.NewRecorder()
+		newHandler(t, variant).ServeHTTP(rr, req)
+
+		assert.Equal(t, location, rr.Header().Get("Location"), variant)
+		assert.Empty(t, rr.Result().Cookies())
+	}
+	assert.Len(t, seen, len(variants))
+
+	// имя варианта в cookie не выбирает вариант, посетитель распределяется заново
+	t.Run("Cookie with variant name", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/ab", nil)
+		req.AddCookie(&http.Cookie{Name: "url_shortener_ab_1", Value: "a"})
+
+		rr := httptest.NewRecorder()
+		newHandler(t, "").ServeHTTP(rr, req)
+
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.NotEqual(t, "a", cookies[0].Value)
+	})
+}
+
+func TestRedirectUniqueVisitor(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	clickSaverMock := mocks.NewClickSaver(t)
+	visitorHasherMock := mocks.NewVisitorHasher(t)
+
+	urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").
+		Return(storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org"}, nil).Once()
+	visitorHasherMock.On("Hash", mock.Anything, netip.MustParseAddr("203.0.113.7"), "Mozilla/5.0", mock.AnythingOfType("time.Time")).
+		Return(uint64(42), nil).Once()
+	clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Visitor: 42}).Return(nil).Once()
+
+	visitorOpts := opts
+	visitorOpts.Visitors = visitorHasherMock
+
+	r := chi.NewRouter()
+	r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, visitorOpts))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/tg", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept-Language", "en")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusFound, rr.Code)
+}
+
+func TestRedirectBot(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		header http.Header
+	}{
+		{
+			name:   "Slack unfurler",
+			method: http.MethodGet,
+			header: http.Header{"User-Agent": {"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"}, "Accept-Language": {"en"}},
+		},
+		{
+			name:   "HEAD request",
+			method: http.MethodHead,
+			header: http.Header{"User-Agent": {"Mozilla/5.0"}, "Accept-Language": {"en"}},
+		},
+		{
+			name:   "No Accept-Language",
+			method: http.MethodGet,
+			header: http.Header{"User-Agent": {"Mozilla/5.0"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+			// бот в уникальные посетители не попадает, поэтому хэш не считается
+			visitorHasherMock := mocks.NewVisitorHasher(t)
+
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").
+				Return(storage.URL{ID: 1, Alias: "tg", URL: "https://web.telegram.org"}, nil).Once()
+			clickSaverMock.On("SaveClick", mock.Anything, storage.Click{URLID: 1, Bot: true}).Return(nil).Once()
+
+			botOpts := opts
+			botOpts.Visitors = visitorHasherMock
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, botOpts))
+
+			req := httptest.NewRequest(tc.method, "http://example.com/tg", nil)
+			req.Header = tc.header
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, "https://web.telegram.org", rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectClickSource(t *testing.T) {
+	cases := []struct {
+		name      string
+		link      storage.URL
+		path      string
+		referer   string
+		wantClick storage.Click
+	}{
+		{
+			name:      "Referrer and source from short link",
+			link:      storage.URL{URL: "https://example.org"},
+			path:      "/tg?utm_source=Newsletter",
+			referer:   "https://www.t.me/s/channel",
+			wantClick: storage.Click{URLID: 1, Referrer: "t.me", Source: "newsletter", Browser: "firefox"},
+		},
+		{
+			name:      "Source from target url",
+			link:      storage.URL{URL: "https://example.org/?utm_source=site"},
+			path:      "/tg",
+			wantClick: storage.Click{URLID: 1, Source: "site", Browser: "firefox"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			clickSaverMock := mocks.NewClickSaver(t)
+
+			tc.link.ID = 1
+			urlGetterMock.On("GetURL", mock.Anything, "example.com", "tg").Return(tc.link, nil).Once()
+			clickSaverMock.On("SaveClick", mock.Anything, tc.wantClick).Return(nil).Once()
+
+			r := chi.NewRouter()
+			r.HandleFunc("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clickSaverMock, opts))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tc.path, nil)
+			req.Header.Set("User-Agent", firefox)
+			req.Header.Set("Accept-Language", "en")
+			if tc.referer != "" {
+				req.Header.Set("Referer", tc.referer)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusFound, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package redirect
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"url-shortener/internal/lib/hostname"
+)
+
+// utm_source длиннее обрезается, чтобы мусор в запросах не раздувал статистику
+const maxSourceLen = 100
+
+// домен страницы, с которой пришёл посетитель, пусто - переход напрямую или браузер не передал Referer
+func referrerDomain(r *http.Request) string {
+	ref := r.Referer()
+	if ref == "" {
+		return ""
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(hostname.Normalize(u.Host), "www.")
+}
+
+// utm_source перехода: из короткой ссылки, если его нет - из адреса, куда она ведёт
+func utmSource(r *http.Request, target string) string {
+	source := r.URL.Query().Get("utm_source")
+	if source == "" {
+		if u, err := url.Parse(target); err == nil {
+			source = u.Query().Get("utm_source")
+		}
+	}
+
+	source = strings.ToLower(strings.TrimSpace(source))
+	if runes := []rune(source); len(runes) > maxSourceLen {
+		source = string(runes[:maxSourceLen])
+	}
+
+	return source
+}
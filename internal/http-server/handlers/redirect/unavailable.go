@@ -0,0 +1,88 @@
+package redirect
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// данные для страницы, которую видит браузер, если ссылка не открывается
+type PageData struct {
+	Code  int
+	Title string
+	Alias string
+}
+
+// страница по умолчанию, свою можно задать в конфиге (redirect.not_found_page)
+var DefaultNotFoundPage = template.Must(template.New("not_found").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; color: #222; }
+main { text-align: center; }
+h1 { font-size: 4rem; margin: 0; color: #888; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Code}}</h1>
+<p>{{.Title}}</p>
+</main>
+</body>
+</html>
+`))
+
+// ссылку открыть нельзя (нет алиаса, срок истёк, переходы закончились, ещё не начала работать)
+// отправляем на первый непустой запасной адрес, затем на общий из конфига,
+// если их нет - браузеру отдаём html страницу, остальным json с ошибкой
+func unavailable(w http.ResponseWriter, r *http.Request, log *slog.Logger, opts Options, status int, message string, fallbacks ...string) {
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	for _, fallback := range append(fallbacks, opts.FallbackURL) {
+		if fallback != "" {
+			http.Redirect(w, r, fallback, http.StatusFound)
+
+			return
+		}
+	}
+
+	if !acceptsHTML(r) {
+		render.Status(r, status)
+		render.JSON(w, r, resp.Error(message))
+
+		return
+	}
+
+	page := opts.NotFoundPage
+	if page == nil {
+		page = DefaultNotFoundPage
+	}
+
+	title := "Link not found"
+	switch status {
+	case http.StatusGone:
+		title = "This link is no longer available"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := page.Execute(w, PageData{Code: status, Title: title, Alias: chi.URLParam(r, "alias")}); err != nil {
+		log.ErrorContext(r.Context(), "failed to render page", sl.Err(err))
+	}
+}
+
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
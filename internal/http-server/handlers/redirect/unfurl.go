@@ -0,0 +1,40 @@
+package redirect
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// страница для превью ссылки с ограничением переходов: адреса назначения в ней нет,
+// чтобы превью не раскрывало ссылку и не тратило переход
+var unfurlPage = template.Must(template.New("unfurl").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Shared link</title>
+</head>
+<body>
+<p>Open this link in a browser to continue.</p>
+</body>
+</html>
+`))
+
+// отвечаем превью и HEAD запросу без редиректа: переход не засчитывается и не расходуется
+func handleUnfurl(w http.ResponseWriter, r *http.Request, log *slog.Logger) {
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if err := unfurlPage.Execute(w, nil); err != nil {
+		log.ErrorContext(r.Context(), "failed to render unfurl page", sl.Err(err))
+	}
+}
@@ -0,0 +1,66 @@
+package redirect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"url-shortener/internal/storage"
+)
+
+// вариант a/b теста закрепляется за посетителем cookie на ссылку
+func variantCookieName(u storage.URL) string {
+	return "url_shortener_ab_" + strconv.FormatInt(u.ID, 10)
+}
+
+// в cookie пишем хэш имени варианта: net/http не отправляет значения с пробелами, ';', '"' и не ascii символами,
+// а имя варианта может быть любым
+func variantCookieValue(v storage.Variant) string {
+	sum := sha256.Sum256([]byte(v.Name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// выбираем вариант: тот же, что в прошлый раз, если он ещё есть у ссылки, иначе случайный по весам
+func chooseVariant(w http.ResponseWriter, r *http.Request, u storage.URL, opts Options) storage.Variant {
+	if c, err := r.Cookie(variantCookieName(u)); err == nil {
+		for _, v := range u.Variants {
+			if variantCookieValue(v) == c.Value {
+				return v
+			}
+		}
+	}
+
+	v := weightedVariant(u.Variants)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName(u),
+		Value:    variantCookieValue(v),
+		Path:     "/" + u.Alias,
+		MaxAge:   int(opts.VariantCookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return v
+}
+
+// случайный вариант с вероятностью, пропорциональной весу
+func weightedVariant(variants []storage.Variant) storage.Variant {
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+
+	n := rand.IntN(total)
+	for _, v := range variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+
+	return variants[len(variants)-1]
+}
@@ -0,0 +1,82 @@
+package get
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/url/get")
+
+// ответ с настройками ссылки
+type Response struct {
+	resp.Response
+	storage.URL
+}
+
+// интерфейс для получения ссылки по домену и алиасу
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLGetter
+type URLGetter interface {
+	GetURL(ctx context.Context, host string, alias string) (storage.URL, error)
+}
+
+// возвращает обработчик, который отдаёт ссылку по алиасу (?domain=)
+// в отличие от перехода ничего не меняет: переход не засчитывается, вариант a/b теста не выбирается
+func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.get.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		// GetURL для незарегистрированного домена ищет в общем пространстве, здесь нужен точный домен
+		u, err := urlGetter.GetURL(r.Context(), domain, alias)
+		if errors.Is(err, storage.ErrURLNotFound) || err == nil && u.Domain != domain {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "got url", slog.String("alias", alias))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			URL:      u,
+		})
+	}
+}
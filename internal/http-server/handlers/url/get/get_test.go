@@ -0,0 +1,79 @@
+package get_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/get"
+	"url-shortener/internal/http-server/handlers/url/get/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestGetHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		path      string
+		domain    string
+		link      storage.URL
+		getErr    error
+		wantURL   string
+		wantError string
+	}{
+		{
+			name:    "Success",
+			path:    "/url/tg",
+			link:    storage.URL{Alias: "tg", URL: "https://web.telegram.org", MaxClicks: 1},
+			wantURL: "https://web.telegram.org",
+		},
+		{
+			name:    "Domain",
+			path:    "/url/tg?domain=Go.Brand-A.com",
+			domain:  "go.brand-a.com",
+			link:    storage.URL{Domain: "go.brand-a.com", Alias: "tg", URL: "https://google.com"},
+			wantURL: "https://google.com",
+		},
+		{
+			name:      "Not found",
+			path:      "/url/tg",
+			getErr:    storage.ErrURLNotFound,
+			wantError: "not found",
+		},
+		{
+			name:      "Unregistered domain",
+			path:      "/url/tg?domain=go.example.com",
+			domain:    "go.example.com",
+			link:      storage.URL{Alias: "tg", URL: "https://web.telegram.org"},
+			wantError: "not found",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetURL", mock.Anything, tc.domain, "tg").Return(tc.link, tc.getErr).Once()
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}", get.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var res get.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+			assert.Equal(t, tc.wantError, res.Error)
+			assert.Equal(t, tc.wantURL, res.URL.URL)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/url/list")
+
+// ответ со списком url
+type Response struct {
+	resp.Response
+	URLs []storage.URL `json:"urls"`
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// интерфейс для получения списка url
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLLister
+type URLLister interface {
+	ListURLs(ctx context.Context, domain string, limit int, offset int) ([]storage.URL, error)
+}
+
+// возвращает обработчик который отдаёт список url домена постранично (?domain=&limit=&offset=)
+func New(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		limit, err := queryInt(r, "limit", defaultLimit)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			log.InfoContext(r.Context(), "invalid limit", slog.String("limit", r.URL.Query().Get("limit")))
+
+			render.JSON(w, r, resp.Error("invalid limit"))
+
+			return
+		}
+
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil || offset < 0 {
+			log.InfoContext(r.Context(), "invalid offset", slog.String("offset", r.URL.Query().Get("offset")))
+
+			render.JSON(w, r, resp.Error("invalid offset"))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		urls, err := urlLister.ListURLs(r.Context(), domain, limit, offset)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list urls", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "got urls", slog.Int("count", len(urls)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			URLs:     urls,
+		})
+	}
+}
+
+// читаем числовой параметр из query, если его нет - возвращаем значение по умолчанию
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(v)
+}
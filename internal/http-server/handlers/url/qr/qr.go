@@ -0,0 +1,211 @@
+package qr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/lru"
+	"url-shortener/internal/lib/qr"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/url/qr")
+
+// параметры по умолчанию
+const (
+	defaultSize   = 256
+	defaultLevel  = "M"
+	defaultMargin = 4
+	maxMargin     = 16
+)
+
+// интерфейс для получения ссылки по домену и алиасу
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLGetter
+type URLGetter interface {
+	GetURL(ctx context.Context, host string, alias string) (storage.URL, error)
+}
+
+// BaseURL - адрес сервиса для ссылок из общего пространства, пусто - схема и host запроса
+// MaxSize - наибольший размер картинки в пикселях
+// Cache - готовые картинки по короткой ссылке и параметрам, nil - без кэша
+type Options struct {
+	BaseURL string
+	MaxSize int
+	Cache   *lru.Cache[string, []byte]
+}
+
+// возвращает обработчик, который отдаёт qr код короткой ссылки в png или svg
+// GET /url/{alias}/qr?format=png|svg&size=256&level=L|M|Q|H&margin=4&domain=
+// формат можно указать и расширением: /url/{alias}/qr.svg
+func New(log *slog.Logger, urlGetter URLGetter, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.qr.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		params, err := parseOptions(r, opts.MaxSize)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid qr options", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		// GetURL для незарегистрированного домена ищет в общем пространстве, здесь нужен точный домен
+		u, err := urlGetter.GetURL(r.Context(), domain, alias)
+		if errors.Is(err, storage.ErrURLNotFound) || err == nil && u.Domain != domain {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		shortURL := shortURL(r, opts.BaseURL, u)
+
+		// картинка зависит только от короткой ссылки и параметров, поэтому кэш не нужно сбрасывать
+		key := fmt.Sprintf("%s|%s|%d|%s|%d", shortURL, params.Format, params.Size, params.Level, params.Margin)
+
+		var data []byte
+		var cached bool
+		if opts.Cache != nil {
+			data, cached = opts.Cache.Get(key)
+		}
+		if !cached {
+			data, err = qr.Encode(shortURL, params)
+			if errors.Is(err, qr.ErrSizeTooSmall) {
+				log.InfoContext(r.Context(), "qr size too small", slog.Int("size", params.Size))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("size is too small"))
+
+				return
+			}
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to encode qr", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+			if opts.Cache != nil {
+				opts.Cache.Set(key, data, 0)
+			}
+		}
+
+		log.InfoContext(r.Context(), "got qr", slog.String("url", shortURL), slog.String("format", params.Format))
+
+		if params.Format == qr.FormatSVG {
+			w.Header().Set("Content-Type", "image/svg+xml")
+		} else {
+			w.Header().Set("Content-Type", "image/png")
+		}
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		_, _ = w.Write(data)
+	}
+}
+
+// параметры картинки из запроса, незаданные берутся по умолчанию
+func parseOptions(r *http.Request, maxSize int) (qr.Options, error) {
+	q := r.URL.Query()
+
+	opts := qr.Options{
+		Format: q.Get("format"),
+		Size:   defaultSize,
+		Level:  q.Get("level"),
+		Margin: defaultMargin,
+	}
+
+	// расширение /qr.svg отрезает middleware.URLFormat
+	if opts.Format == "" {
+		opts.Format, _ = r.Context().Value(middleware.URLFormatCtxKey).(string)
+	}
+	if opts.Format == "" {
+		opts.Format = qr.FormatPNG
+	}
+	if opts.Format != qr.FormatPNG && opts.Format != qr.FormatSVG {
+		return qr.Options{}, errors.New("format must be png or svg")
+	}
+
+	if opts.Level == "" {
+		opts.Level = defaultLevel
+	}
+	if !qr.ValidLevel(opts.Level) {
+		return qr.Options{}, errors.New("level must be L, M, Q or H")
+	}
+
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > maxSize {
+			return qr.Options{}, fmt.Errorf("size must be from 1 to %d", maxSize)
+		}
+		opts.Size = size
+	}
+
+	if v := q.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > maxMargin {
+			return qr.Options{}, fmt.Errorf("margin must be from 0 to %d", maxMargin)
+		}
+		opts.Margin = margin
+	}
+
+	return opts, nil
+}
+
+// полная короткая ссылка: алиас в своём домене открывается по этому домену
+func shortURL(r *http.Request, baseURL string, u storage.URL) string {
+	base := &url.URL{Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		base.Scheme = "https"
+	}
+	if baseURL != "" {
+		if parsed, err := url.Parse(baseURL); err == nil {
+			base = parsed
+		}
+	}
+	if u.Domain != "" {
+		base = &url.URL{Scheme: base.Scheme, Host: u.Domain}
+	}
+
+	return base.JoinPath(u.Alias).String()
+}
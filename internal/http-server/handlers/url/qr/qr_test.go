@@ -0,0 +1,98 @@
+package qr_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"url-shortener/internal/http-server/handlers/url/qr"
+	"url-shortener/internal/http-server/handlers/url/qr/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/lru"
+	"url-shortener/internal/storage"
+)
+
+func TestQRHandler(t *testing.T) {
+	cases := []struct {
+		name            string
+		path            string
+		link            storage.URL
+		getErr          error
+		wantStatus      int
+		wantContentType string
+	}{
+		{
+			name:            "PNG by default",
+			path:            "/url/tg/qr",
+			link:            storage.URL{Alias: "tg"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "image/png",
+		},
+		{
+			name:            "SVG by extension",
+			path:            "/url/tg/qr.svg?level=H&margin=0",
+			link:            storage.URL{Alias: "tg"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "image/svg+xml",
+		},
+		{
+			name:       "Not found",
+			path:       "/url/tg/qr",
+			getErr:     storage.ErrURLNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Unregistered domain",
+			path:       "/url/tg/qr?domain=go.example.com",
+			link:       storage.URL{Alias: "tg"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid level",
+			path:       "/url/tg/qr?level=X",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Size too large",
+			path:       "/url/tg/qr?size=5000",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+
+			if tc.wantStatus != http.StatusBadRequest {
+				urlGetterMock.On("GetURL", mock.Anything, mock.AnythingOfType("string"), "tg").
+					Return(tc.link, tc.getErr).Once()
+			}
+
+			r := chi.NewRouter()
+			r.Use(middleware.URLFormat)
+			r.Get("/url/{alias}/qr", qr.New(slogdiscard.NewDiscardLogger(), urlGetterMock, qr.Options{
+				BaseURL: "https://sho.rt",
+				MaxSize: 1024,
+				Cache:   lru.New[string, []byte](10),
+			}))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			if tc.wantContentType != "" {
+				assert.Equal(t, tc.wantContentType, rr.Header().Get("Content-Type"))
+				assert.NotEmpty(t, rr.Body.Bytes())
+			}
+			if tc.wantContentType == "image/svg+xml" {
+				assert.True(t, strings.HasPrefix(rr.Body.String(), "<svg"))
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Code generated by mockery v2.44.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// UTMTemplateGetter is an autogenerated mock type for the UTMTemplateGetter type
+type UTMTemplateGetter struct {
+	mock.Mock
+}
+
+// GetUTMTemplate provides a mock function with given fields: ctx, name
+func (_m *UTMTemplateGetter) GetUTMTemplate(ctx context.Context, name string) (storage.UTMTemplate, error) {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUTMTemplate")
+	}
+
+	var r0 storage.UTMTemplate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.UTMTemplate, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) storage.UTMTemplate); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Get(0).(storage.UTMTemplate)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewUTMTemplateGetter creates a new instance of UTMTemplateGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUTMTemplateGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UTMTemplateGetter {
+	mock := &UTMTemplateGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,26 +1,90 @@
 package save
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
+	"url-shortener/internal/lib/hostname"
 	resp "url-shortener/internal/lib/logger/api/response"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/metrics"
 	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/lib/utm"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var tracer = tracing.Tracer("handlers/url/save")
+
 // к нам будет поступать запрос, к котором будет находиться json объект, который описывает url, который нужно сохранить
 // validate говорит говорит validator'у что поле URL обязательное, а также валидатор будет определять действительно url лежит в этом поле
+// Domain - зарегистрированный домен, в котором создаётся алиас, пусто - общее пространство
+// RedirectStatus - код ответа редиректа, если не указан - берётся из конфига
+// ForwardQuery, ForwardPath, QueryConflict - передача параметров и пути после алиаса (см. storage.URL)
+// UTMTemplate - имя шаблона, параметры которого добавляются к url
+// Password - пароль на открытие ссылки, хранится только его хэш
+// MaxClicks - сколько раз можно перейти по ссылке (1 - одноразовая), 0 - без ограничения
+// ActiveFrom, ExpiresAt - время начала и окончания работы ссылки (RFC 3339)
+// FallbackURL - куда отправлять, когда ссылка истекла или закончились переходы
+// Title - название ссылки, показывается на странице просмотра (/{alias}+)
+// "+" в алиасе не допускается: /{alias}+ - это страница просмотра
+// Targets - адреса для части посетителей по User-Agent, проверяются по порядку, url - если ни один не подошёл
+// Variants - a/b тест: остальные посетители распределяются между вариантами по весу вместо url
 type Request struct {
-	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	URL            string     `json:"url" validate:"required,url"`
+	Alias          string     `json:"alias,omitempty" validate:"excludes=+"`
+	Domain         string     `json:"domain,omitempty"`
+	RedirectStatus int        `json:"redirect_status,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	ForwardQuery   bool       `json:"forward_query,omitempty"`
+	ForwardPath    bool       `json:"forward_path,omitempty"`
+	QueryConflict  string     `json:"query_conflict,omitempty" validate:"omitempty,oneof=keep override append"`
+	UTMTemplate    string     `json:"utm_template,omitempty"`
+	Password       string     `json:"password,omitempty"`
+	MaxClicks      int64      `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+	ActiveFrom     *time.Time `json:"active_from,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	FallbackURL    string     `json:"fallback_url,omitempty" validate:"omitempty,url"`
+	Title          string     `json:"title,omitempty" validate:"max=200"`
+	Targets        []Target   `json:"targets,omitempty" validate:"max=20,dive"`
+	Variants       []Variant  `json:"variants,omitempty" validate:"omitempty,min=2,max=10,dive"`
+}
+
+// вариант a/b теста, имя попадает в статистику переходов
+type Variant struct {
+	Name   string `json:"name" validate:"required,max=50"`
+	URL    string `json:"url" validate:"required,url"`
+	Weight int    `json:"weight" validate:"required,min=1,max=1000"`
+}
+
+// адрес для части посетителей, нужно хотя бы одно условие (см. storage.Target)
+type Target struct {
+	OS        string   `json:"os,omitempty" validate:"omitempty,oneof=ios android windows macos linux chromeos"`
+	Device    string   `json:"device,omitempty" validate:"omitempty,oneof=mobile tablet desktop"`
+	Bot       *bool    `json:"bot,omitempty"`
+	Countries []string `json:"countries,omitempty" validate:"max=250,dive,len=2,alpha"`
+	URL       string   `json:"url" validate:"required,url"`
+}
+
+// пароль не должен попадать в логи
+func (r Request) LogValue() slog.Value {
+	if r.Password != "" {
+		r.Password = "[hidden]"
+	}
+
+	// отдельный тип без LogValue, иначе будет бесконечная рекурсия
+	type request Request
+	return slog.AnyValue(request(r))
 }
 
 // ответ от сервиса
@@ -31,17 +95,43 @@ type Response struct {
 
 const aliasLenght = 6
 
+// алиасы, которые совпадают с путями самого сервиса (cmd/url-shortener/main.go):
+// роутер отдаёт такой путь обработчику сервиса, и ссылка никогда бы не открылась
+var reservedAliases = map[string]bool{
+	"url":     true,
+	"domain":  true,
+	"utm":     true,
+	"metrics": true,
+	"healthz": true,
+	"readyz":  true,
+}
+
+// bcrypt работает не больше чем с 72 байтами пароля
+// validator (max=) считает символы, а не байты, поэтому длина проверяется отдельно
+const maxPasswordBytes = 72
+
 //go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLSaver
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
+	SaveURL(ctx context.Context, u storage.URL) (int64, error)
+}
+
+// интерфейс для получения шаблона utm параметров
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=UTMTemplateGetter
+type UTMTemplateGetter interface {
+	GetUTMTemplate(ctx context.Context, name string) (storage.UTMTemplate, error)
 }
 
 // Наш Storage(sqlite) реализует интерфейс URLSaver
 // здесь будет возвращаться обработчик, который обрабатывает запрос
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+func New(log *slog.Logger, urlSaver URLSaver, templateGetter UTMTemplateGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
 		// аргументы функции With() будут добавлятся к каждому выводу лога; GetReqID - задёт номер запроса
 		log = log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
 
@@ -51,7 +141,7 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 		err := render.DecodeJSON(r.Body, &req)
 		if err != nil && err != io.EOF {
 			// пишем ошибку в лог
-			log.Error("failed to decode request body", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
 
 			// возвращаем json с ответом клиенту, если ошибка(тело ответа)
 			render.JSON(w, r, resp.Error("failed to decode request"))
@@ -60,14 +150,14 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 		}
 
 		// сообщаем об успешном декодировании
-		log.Info("request body decoded", slog.Any("request", req))
+		log.InfoContext(r.Context(), "request body decoded", slog.Any("request", req))
 
 		// проверяем на валидацию данных структуру (json - запрос), если получена ошибка, то
 		// получаем список ошибок и функция ValidationError(), вернёт информацию по каждой ошибке на понятном языке
 		if err := validator.New().Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
 
-			log.Error("invalid request", sl.Err(err))
+			log.ErrorContext(r.Context(), "invalid request", sl.Err(err))
 
 			// возвращаем json с ответом клиенту, если ошибка(в виде html)
 			render.JSON(w, r, resp.ValidationError(validateErr))
@@ -75,14 +165,151 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			return
 		}
 
+		if !storage.ValidWindow(req.ActiveFrom, req.ExpiresAt) {
+			log.InfoContext(r.Context(), "invalid window")
+
+			render.JSON(w, r, resp.Error("expires_at must be after active_from"))
+
+			return
+		}
+
+		if reservedAliases[req.Alias] {
+			log.InfoContext(r.Context(), "alias is reserved", slog.String("alias", req.Alias))
+
+			render.JSON(w, r, resp.Error("alias is reserved"))
+
+			return
+		}
+
+		if len(req.Password) > maxPasswordBytes {
+			log.InfoContext(r.Context(), "password too long", slog.Int("bytes", len(req.Password)))
+
+			render.JSON(w, r, resp.Error(fmt.Sprintf("password must be at most %d bytes", maxPasswordBytes)))
+
+			return
+		}
+
+		names := make(map[string]bool, len(req.Variants))
+		for _, v := range req.Variants {
+			if names[v.Name] {
+				log.InfoContext(r.Context(), "duplicate variant name", slog.String("name", v.Name))
+
+				render.JSON(w, r, resp.Error("variant names must be unique"))
+
+				return
+			}
+			names[v.Name] = true
+		}
+
+		for _, t := range req.Targets {
+			if t.OS == "" && t.Device == "" && t.Bot == nil && len(t.Countries) == 0 {
+				log.InfoContext(r.Context(), "target without condition")
+
+				render.JSON(w, r, resp.Error("target must have os, device, bot or countries condition"))
+
+				return
+			}
+		}
+
 		var id int64
 		alias := req.Alias
+		u := storage.URL{
+			Domain:         hostname.Normalize(req.Domain),
+			URL:            req.URL,
+			RedirectStatus: req.RedirectStatus,
+			ForwardQuery:   req.ForwardQuery,
+			ForwardPath:    req.ForwardPath,
+			QueryConflict:  req.QueryConflict,
+			UTMTemplate:    req.UTMTemplate,
+			MaxClicks:      req.MaxClicks,
+			ActiveFrom:     req.ActiveFrom,
+			ExpiresAt:      req.ExpiresAt,
+			FallbackURL:    req.FallbackURL,
+			Title:          req.Title,
+		}
+		for _, t := range req.Targets {
+			target := storage.Target{OS: t.OS, Device: t.Device, Bot: t.Bot, URL: t.URL}
+			// коды стран в базе geoip в верхнем регистре
+			for _, country := range t.Countries {
+				target.Countries = append(target.Countries, strings.ToUpper(country))
+			}
+			u.Targets = append(u.Targets, target)
+		}
+		for _, v := range req.Variants {
+			u.Variants = append(u.Variants, storage.Variant{Name: v.Name, URL: v.URL, Weight: v.Weight})
+		}
+
+		// длина пароля в байтах уже проверена выше
+		if req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to hash password", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to add url"))
+
+				return
+			}
+			u.PasswordHash = string(hash)
+		}
+
+		// сохраняем url уже с параметрами шаблона
+		if req.UTMTemplate != "" {
+			tmpl, err := templateGetter.GetUTMTemplate(r.Context(), req.UTMTemplate)
+			if errors.Is(err, storage.ErrUTMTemplateNotFound) {
+				log.InfoContext(r.Context(), "utm template not found", slog.String("utm_template", req.UTMTemplate))
+
+				render.JSON(w, r, resp.Error("utm template not found"))
+
+				return
+			}
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to get utm template", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to add url"))
+
+				return
+			}
+
+			u.URL, err = utm.Apply(u.URL, tmpl)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to apply utm template", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to add url"))
+
+				return
+			}
+			for i := range u.Targets {
+				u.Targets[i].URL, err = utm.Apply(u.Targets[i].URL, tmpl)
+				if err != nil {
+					log.ErrorContext(r.Context(), "failed to apply utm template", sl.Err(err))
+
+					render.JSON(w, r, resp.Error("failed to add url"))
+
+					return
+				}
+			}
+			for i := range u.Variants {
+				u.Variants[i].URL, err = utm.Apply(u.Variants[i].URL, tmpl)
+				if err != nil {
+					log.ErrorContext(r.Context(), "failed to apply utm template", sl.Err(err))
+
+					render.JSON(w, r, resp.Error("failed to add url"))
+
+					return
+				}
+			}
+		}
 		// если алиас пустой, то будем генерировать, избегая ошибки генерации алиаса, который уже существовал
 		if alias == "" {
 			for {
 				alias = random.NewRandomString(aliasLenght)
-				id, err = urlSaver.SaveURL(req.URL, alias)
+				if reservedAliases[alias] {
+					continue
+				}
+				u.Alias = alias
+				id, err = urlSaver.SaveURL(r.Context(), u)
 				if errors.Is(err, storage.ErrURLExists) {
+					metrics.AliasCollisions.Inc()
 					continue
 				} else {
 					break
@@ -90,19 +317,29 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			}
 		} else {
 			// сохраняем url
-			id, err = urlSaver.SaveURL(req.URL, alias)
+			u.Alias = alias
+			id, err = urlSaver.SaveURL(r.Context(), u)
 			// обработка ошибки если алиас существует
 			if errors.Is(err, storage.ErrURLExists) {
-				log.Info("url already exists", slog.String("url", req.URL))
+				log.InfoContext(r.Context(), "url already exists", slog.String("url", req.URL))
 
-				render.JSON(w, r, resp.Error("url already exists"))
+				render.JSON(w, r, resp.ErrorCode(resp.CodeURLExists, "url already exists"))
 
 				return
 			}
 		}
 
+		// домен не зарегистрирован
+		if errors.Is(err, storage.ErrDomainNotFound) {
+			log.InfoContext(r.Context(), "domain not found", slog.String("domain", u.Domain))
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeDomainNotFound, "domain not found"))
+
+			return
+		}
+
 		if err != nil {
-			log.Error("failed to add url", sl.Err(err))
+			log.ErrorContext(r.Context(), "failed to add url", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("failed to add url"))
 
@@ -110,7 +347,7 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 		}
 
 		// сообщаем об успешном добавлении url
-		log.Info("url added", slog.Int64("id", id))
+		log.InfoContext(r.Context(), "url added", slog.Int64("id", id))
 
 		// возвращаем статус ок
 		responseOK(w, r, alias)
@@ -7,14 +7,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"url-shortener/internal/http-server/handlers/url/save"
 	"url-shortener/internal/http-server/handlers/url/save/mocks"
+	"url-shortener/internal/lib/hostname"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
 )
 
 // табличные тесты
@@ -23,8 +27,14 @@ func TestSaveHandler(t *testing.T) {
 		name      string
 		alias     string
 		url       string
+		domain    string
 		respError string
 		mockError error
+		// шаблон utm и url, который должен попасть в хранилище
+		utmTemplate   string
+		templateError error
+		savedURL      string
+		password      string
 	}{
 		{
 			name:  "Success",
@@ -55,6 +65,67 @@ func TestSaveHandler(t *testing.T) {
 			respError: "failed to add url",
 			mockError: errors.New("unexpected error"),
 		},
+		{
+			name:   "Custom domain",
+			alias:  "test_alias",
+			url:    "https://google.com",
+			domain: "Go.Example.com:443",
+		},
+		{
+			name:      "Unknown domain",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			domain:    "unknown.example.com",
+			respError: "domain not found",
+			mockError: storage.ErrDomainNotFound,
+		},
+		{
+			name:        "UTM template",
+			alias:       "test_alias",
+			url:         "https://google.com?utm_medium=cpc",
+			utmTemplate: "spring",
+			savedURL:    "https://google.com?utm_campaign=spring&utm_medium=cpc&utm_source=telegram",
+		},
+		{
+			name:          "Unknown UTM template",
+			alias:         "test_alias",
+			url:           "https://google.com",
+			utmTemplate:   "missing",
+			respError:     "utm template not found",
+			templateError: storage.ErrUTMTemplateNotFound,
+		},
+		{
+			name:     "Password",
+			alias:    "test_alias",
+			url:      "https://google.com",
+			password: "secret",
+		},
+		{
+			name:      "Reserved alias",
+			alias:     "metrics",
+			url:       "https://google.com",
+			respError: "alias is reserved",
+		},
+		{
+			name:      "Health check alias",
+			alias:     "readyz",
+			url:       "https://google.com",
+			respError: "alias is reserved",
+		},
+		{
+			name:     "Password of 72 bytes",
+			alias:    "test_alias",
+			url:      "https://google.com",
+			password: strings.Repeat("p", 72),
+		},
+		{
+			// 72 символа кириллицы - 144 байта, bcrypt такой пароль не принимает
+			name:      "Password longer than 72 bytes",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			password:  strings.Repeat("п", 72),
+			respError: "password must be at most 72 bytes",
+		},
 	}
 
 	for _, tc := range cases {
@@ -64,16 +135,33 @@ func TestSaveHandler(t *testing.T) {
 			t.Parallel()
 
 			urlSaverMock := mocks.NewURLSaver(t)
+			templateGetterMock := mocks.NewUTMTemplateGetter(t)
+
+			if tc.utmTemplate != "" {
+				templateGetterMock.On("GetUTMTemplate", mock.Anything, tc.utmTemplate).
+					Return(storage.UTMTemplate{Name: tc.utmTemplate, Source: "telegram", Medium: "social", Campaign: "spring"}, tc.templateError).
+					Once()
+			}
+
+			savedURL := tc.url
+			if tc.savedURL != "" {
+				savedURL = tc.savedURL
+			}
 
 			if tc.respError == "" || tc.mockError != nil {
-				urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+				urlSaverMock.On("SaveURL", mock.Anything, mock.MatchedBy(func(u storage.URL) bool {
+					// домен приходит в хранилище нормализованным
+					return u.URL == savedURL && u.Domain == hostname.Normalize(tc.domain) && u.Alias != "" &&
+						u.UTMTemplate == tc.utmTemplate && passwordMatches(u.PasswordHash, tc.password)
+				})).
 					Return(int64(1), tc.mockError).
 					Once()
 			}
 
-			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock)
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, templateGetterMock)
 
-			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"}`, tc.url, tc.alias)
+			input := fmt.Sprintf(`{"url": "%s", "alias": "%s", "domain": "%s", "utm_template": "%s", "password": "%s"}`,
+				tc.url, tc.alias, tc.domain, tc.utmTemplate, tc.password)
 
 			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(input)))
 			require.NoError(t, err)
@@ -95,3 +183,12 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+// в хранилище попадает только хэш пароля
+func passwordMatches(hash string, password string) bool {
+	if password == "" {
+		return hash == ""
+	}
+
+	return hash != password && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/url/stats")
+
+// длина списков самых частых источников
+const (
+	defaultTop = 10
+	maxTop     = 100
+)
+
+// ответ со статистикой по алиасу
+type Response struct {
+	resp.Response
+	storage.Stats
+}
+
+// интерфейс для получения статистики
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=StatsGetter
+type StatsGetter interface {
+	GetStats(ctx context.Context, domain string, alias string, q storage.StatsQuery) (storage.Stats, error)
+	UniqueVisitors(ctx context.Context, domain string, alias string, from time.Time, to time.Time) (int64, error)
+	Breakdown(ctx context.Context, domain string, alias string, q storage.StatsQuery, limit int) (storage.Breakdown, error)
+}
+
+// возвращает обработчик который отдаёт статистику переходов по алиасу, домен алиаса передаётся в ?domain=
+// все числа ответа (переходы, варианты, уникальные посетители, источники) считаются за дни с ?from= по ?to=
+// (YYYY-MM-DD, UTC, без них - за всё время), период и класс возвращаются в ответе
+// ?class=human|bot оставляет только переходы людей или ботов, для ботов unique_visitors не отдаётся
+// ?top= (по умолчанию 10) - длина списков самых частых доменов referrer, utm_source и браузеров
+func New(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.stats.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		from, to, err := parseRange(r)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid date range", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		top, err := parseTop(r)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid top", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		class := r.URL.Query().Get("class")
+		if class != "" && class != storage.ClassHuman && class != storage.ClassBot {
+			log.InfoContext(r.Context(), "invalid click class", slog.String("class", class))
+
+			render.JSON(w, r, resp.Error("class must be human or bot"))
+
+			return
+		}
+
+		q := storage.StatsQuery{From: from, To: to, Class: class}
+
+		stats, err := statsGetter.GetStats(r.Context(), domain, alias, q)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get stats", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		// у ботов хэш посетителя не считается, поэтому для них уникальных нет и поле не отдаём
+		if class != storage.ClassBot {
+			unique, err := statsGetter.UniqueVisitors(r.Context(), domain, alias, from, to)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to get unique visitors", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+			stats.UniqueVisitors = &unique
+		}
+
+		stats.Breakdown, err = statsGetter.Breakdown(r.Context(), domain, alias, q, top)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get breakdown", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "got stats", slog.String("alias", alias), slog.Int64("clicks", stats.Clicks))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Stats:    stats,
+		})
+	}
+}
+
+// период из ?from= и ?to=, незаданная граница - нулевое время
+func parseRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", p.name)
+		}
+		*p.dst = t
+	}
+
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+
+	return from, to, nil
+}
+
+// длина списков из ?top=
+func parseTop(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("top")
+	if v == "" {
+		return defaultTop, nil
+	}
+
+	top, err := strconv.Atoi(v)
+	if err != nil || top < 1 || top > maxTop {
+		return 0, fmt.Errorf("top must be from 1 to %d", maxTop)
+	}
+
+	return top, nil
+}
@@ -0,0 +1,103 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+var tracer = tracing.Tracer("handlers/url/update")
+
+// новый url, на который будет вести алиас
+type Request struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+// интерфейс для обновления url
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=URLUpdater
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, domain string, alias string, newURL string) error
+}
+
+// возвращает обработчик который меняет url у существующего алиаса, домен алиаса передаётся в ?domain=
+func New(log *slog.Logger, urlUpdater URLUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		// получаем параметр alias из роутера, т.е. /{alias}
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && err != io.EOF {
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "request body decoded", slog.Any("request", req))
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.ErrorContext(r.Context(), "invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		err = urlUpdater.UpdateURL(r.Context(), domain, alias, req.URL)
+		// если алиас не найден
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to update url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to update url"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "url updated", slog.String("alias", alias), slog.String("url", req.URL))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
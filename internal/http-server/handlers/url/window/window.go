@@ -0,0 +1,100 @@
+package window
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/lib/hostname"
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/url/window")
+
+// новое время работы ссылки (RFC 3339), не указанное поле снимает ограничение
+type Request struct {
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// интерфейс для изменения времени работы ссылки
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=WindowUpdater
+type WindowUpdater interface {
+	UpdateWindow(ctx context.Context, domain string, alias string, activeFrom *time.Time, expiresAt *time.Time) error
+}
+
+// возвращает обработчик который меняет время работы ссылки, домен алиаса передаётся в ?domain=
+func New(log *slog.Logger, windowUpdater WindowUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.window.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.InfoContext(r.Context(), "alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && err != io.EOF {
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "request body decoded", slog.Any("request", req))
+
+		if !storage.ValidWindow(req.ActiveFrom, req.ExpiresAt) {
+			log.InfoContext(r.Context(), "invalid window")
+
+			render.JSON(w, r, resp.Error("expires_at must be after active_from"))
+
+			return
+		}
+
+		domain := hostname.Normalize(r.URL.Query().Get("domain"))
+
+		err = windowUpdater.UpdateWindow(r.Context(), domain, alias, req.ActiveFrom, req.ExpiresAt)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.InfoContext(r.Context(), "url not found", "alias", alias, "domain", domain)
+
+			render.JSON(w, r, resp.ErrorCode(resp.CodeURLNotFound, "not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to update window", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to update url"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "window updated", slog.String("alias", alias))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
@@ -0,0 +1,72 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/utm/delete")
+
+// интерфейс для удаления шаблона
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=UTMTemplateDeleter
+type UTMTemplateDeleter interface {
+	DeleteUTMTemplate(ctx context.Context, name string) error
+}
+
+// возвращает обработчик который удаляет шаблон utm параметров
+func New(log *slog.Logger, templateDeleter UTMTemplateDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.utm.delete.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		// точку в имени middleware.URLFormat считает расширением, возвращаем отрезанную часть
+		name := chi.URLParam(r, "name")
+		if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format != "" {
+			name += "." + format
+		}
+		if name == "" {
+			log.InfoContext(r.Context(), "name is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		err := templateDeleter.DeleteUTMTemplate(r.Context(), name)
+		if errors.Is(err, storage.ErrUTMTemplateNotFound) {
+			log.InfoContext(r.Context(), "utm template not found", slog.String("name", name))
+
+			render.JSON(w, r, resp.Error("utm template not found"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to delete utm template", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "utm template deleted", slog.String("name", name))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
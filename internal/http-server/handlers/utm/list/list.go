@@ -0,0 +1,59 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var tracer = tracing.Tracer("handlers/utm/list")
+
+// ответ со списком шаблонов
+type Response struct {
+	resp.Response
+	Templates []storage.UTMTemplate `json:"templates"`
+}
+
+// интерфейс для получения списка шаблонов
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=UTMTemplateLister
+type UTMTemplateLister interface {
+	ListUTMTemplates(ctx context.Context) ([]storage.UTMTemplate, error)
+}
+
+// возвращает обработчик который отдаёт список шаблонов utm параметров
+func New(log *slog.Logger, templateLister UTMTemplateLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.utm.list.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		templates, err := templateLister.ListUTMTemplates(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list utm templates", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "got utm templates", slog.Int("count", len(templates)))
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Templates: templates,
+		})
+	}
+}
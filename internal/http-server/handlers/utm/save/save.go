@@ -0,0 +1,96 @@
+package save
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	resp "url-shortener/internal/lib/logger/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+var tracer = tracing.Tracer("handlers/utm/save")
+
+// шаблон utm параметров, в значениях можно использовать {date}, {year}, {month}, {alias} и {host}
+type Request struct {
+	Name     string `json:"name" validate:"required,max=64"`
+	Source   string `json:"source" validate:"required"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// интерфейс для сохранения шаблона
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.44.2 --name=UTMTemplateSaver
+type UTMTemplateSaver interface {
+	SaveUTMTemplate(ctx context.Context, t storage.UTMTemplate) error
+}
+
+// возвращает обработчик который сохраняет шаблон utm параметров
+func New(log *slog.Logger, templateSaver UTMTemplateSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.utm.save.New"
+
+		ctx, span := tracer.Start(r.Context(), op)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		log := log.With(slog.String("op", op), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && err != io.EOF {
+			log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.ErrorContext(r.Context(), "invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		err = templateSaver.SaveUTMTemplate(r.Context(), storage.UTMTemplate{
+			Name:     req.Name,
+			Source:   req.Source,
+			Medium:   req.Medium,
+			Campaign: req.Campaign,
+			Content:  req.Content,
+		})
+		if errors.Is(err, storage.ErrUTMTemplateExists) {
+			log.InfoContext(r.Context(), "utm template already exists", slog.String("name", req.Name))
+
+			render.JSON(w, r, resp.Error("utm template already exists"))
+
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to add utm template", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to add utm template"))
+
+			return
+		}
+
+		log.InfoContext(r.Context(), "utm template added", slog.String("name", req.Name))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
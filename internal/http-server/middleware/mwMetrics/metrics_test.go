@@ -0,0 +1,31 @@
+package mwMetrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/http-server/middleware/mwMetrics"
+	"url-shortener/internal/lib/metrics"
+)
+
+func TestMetrics_RoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(mwMetrics.New())
+	r.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://google.com", http.StatusFound)
+	})
+
+	for _, alias := range []string{"a", "b", "c"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+alias, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b/c", nil))
+
+	// все алиасы попадают в один ряд с шаблоном роута
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/{alias}", "302")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")))
+}
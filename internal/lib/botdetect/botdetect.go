@@ -0,0 +1,67 @@
+package botdetect
+
+import (
+	"net/http"
+	"strings"
+)
+
+// причины, по которым запрос считается ботом
+const (
+	ReasonUserAgent      = "user_agent"
+	ReasonEmptyUserAgent = "empty_user_agent"
+	ReasonHead           = "head_request"
+	ReasonNoLanguage     = "no_accept_language"
+)
+
+// бот ли это по User-Agent
+func UserAgent(ua string) bool {
+	return Unfurler(ua) || contains(ua, uaPatterns)
+}
+
+// сервис превью ссылок по User-Agent
+func Unfurler(ua string) bool {
+	return contains(ua, unfurlerPatterns)
+}
+
+func contains(ua string, patterns []string) bool {
+	s := strings.ToLower(ua)
+	for _, p := range patterns {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// классифицируем запрос: известный User-Agent или признаки не браузера
+// возвращается причина, пустая строка - похоже на человека
+func Classify(r *http.Request) string {
+	ua := r.UserAgent()
+
+	switch {
+	case ua == "":
+		return ReasonEmptyUserAgent
+	case UserAgent(ua):
+		return ReasonUserAgent
+	// HEAD присылают проверки ссылок, браузер так не переходит
+	case r.Method == http.MethodHead:
+		return ReasonHead
+	// браузеры всегда присылают Accept-Language
+	case r.Header.Get("Accept-Language") == "":
+		return ReasonNoLanguage
+	}
+
+	return ""
+}
+
+func IsBot(r *http.Request) bool {
+	return Classify(r) != ""
+}
+
+// запрос без участия человека, которому нельзя отдавать то, что расходуется при переходе:
+// превью ссылки в мессенджере или HEAD от проверки ссылок
+// curl, wget и скрипты сюда не относятся, ими ссылку открывают намеренно (например, скачивание файла)
+func IsUnfurl(r *http.Request) bool {
+	return r.Method == http.MethodHead || Unfurler(r.UserAgent())
+}
@@ -0,0 +1,133 @@
+package botdetect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAgent(t *testing.T) {
+	bots := []string{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+		"Mozilla/5.0 (compatible; YandexMobileBot/3.0; +http://yandex.com/bots)",
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+		"TelegramBot (like TwitterBot)",
+		"WhatsApp/2.23.20.0 A",
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+		"curl/8.5.0",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36",
+	}
+	for _, ua := range bots {
+		assert.True(t, UserAgent(ua), ua)
+	}
+
+	// настоящие браузеры и приложения, в которых есть похожие слова
+	humans := []string{
+		// YaBrowser
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 YaBrowser/24.4.0.0 Safari/537.36",
+		// телефон Cubot
+		"Mozilla/5.0 (Linux; Android 11; CUBOT KINGKONG 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+		// android приложение на okhttp
+		"okhttp/4.12.0",
+		// встроенный браузер Pinterest
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36 [Pinterest/Android]",
+		// Samsung Internet
+		"Mozilla/5.0 (Linux; Android 14; SM-S921B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+		// встроенный браузер Яндекса на iOS
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 YaBrowser/24.4.5.436 Mobile/15E148 Safari/604.1",
+	}
+	for _, ua := range humans {
+		assert.False(t, UserAgent(ua), ua)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	cases := []struct {
+		name     string
+		method   string
+		ua       string
+		language string
+		want     string
+	}{
+		{
+			name:     "Browser",
+			method:   http.MethodGet,
+			ua:       chrome,
+			language: "en-US,en;q=0.9",
+		},
+		{
+			name:     "Slack unfurler",
+			method:   http.MethodGet,
+			ua:       "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			language: "en",
+			want:     ReasonUserAgent,
+		},
+		{
+			name:   "Uptime checker",
+			method: http.MethodGet,
+			ua:     "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+			want:   ReasonUserAgent,
+		},
+		{
+			name:   "Empty user agent",
+			method: http.MethodGet,
+			want:   ReasonEmptyUserAgent,
+		},
+		{
+			name:     "HEAD request",
+			method:   http.MethodHead,
+			ua:       chrome,
+			language: "en",
+			want:     ReasonHead,
+		},
+		{
+			name:   "No Accept-Language",
+			method: http.MethodGet,
+			ua:     chrome,
+			want:   ReasonNoLanguage,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/tg", nil)
+			r.Header.Set("User-Agent", tc.ua)
+			if tc.language != "" {
+				r.Header.Set("Accept-Language", tc.language)
+			}
+
+			assert.Equal(t, tc.want, Classify(r))
+		})
+	}
+}
+
+func TestIsUnfurl(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		ua     string
+		want   bool
+	}{
+		{name: "Slack unfurler", method: http.MethodGet, ua: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", want: true},
+		{name: "Telegram preview", method: http.MethodGet, ua: "TelegramBot (like TwitterBot)", want: true},
+		{name: "HEAD request", method: http.MethodHead, ua: "curl/8.5.0", want: true},
+		{name: "curl", method: http.MethodGet, ua: "curl/8.5.0"},
+		{name: "wget", method: http.MethodGet, ua: "Wget/1.21.4"},
+		{name: "Script", method: http.MethodGet, ua: "python-requests/2.31.0"},
+		{name: "Crawler", method: http.MethodGet, ua: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/tg", nil)
+			r.Header.Set("User-Agent", tc.ua)
+
+			assert.Equal(t, tc.want, IsUnfurl(r))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package botdetect
+
+// подстроки User-Agent (в нижнем регистре), по которым запрос считается ботом
+// только полные имена краулеров и клиентов: общие слова вроде "bot" или "yandex" встречаются
+// и у настоящих браузеров (Cubot, YaBrowser), а неизвестных ботов отсекают эвристики в Classify
+// новые боты добавляются в подходящую группу
+var uaPatterns = []string{
+	// общие признаки краулеров
+	"crawler", "spider",
+
+	// поисковые системы
+	"googlebot", "adsbot-google", "mediapartners-google", "google-inspectiontool", "bingbot",
+	"yandex.com/bots", "baiduspider", "duckduckbot", "applebot", "petalbot", "seznambot",
+
+	// seo сервисы
+	"ahrefsbot", "semrushbot", "mj12bot", "dotbot", "screaming frog",
+
+	// мониторинг доступности
+	"uptimerobot", "pingdom", "statuscake", "site24x7", "betteruptime", "better uptime bot",
+	"datadogsynthetics", "newrelicpinger", "checkly", "hetrixtools",
+
+	// http клиенты и утилиты
+	"curl/", "wget/", "httpie/", "python-requests", "python-urllib", "aiohttp", "go-http-client",
+	"java/", "apache-httpclient", "libwww-perl", "axios/", "node-fetch", "undici",
+	"headlesschrome", "phantomjs", "chrome-lighthouse",
+}
+
+// сервисы, которые строят превью ссылок в мессенджерах и соцсетях
+// они запрашивают ссылку сами, без участия человека, ещё до того, как её кто то откроет
+var unfurlerPatterns = []string{
+	"facebookexternalhit", "facebot", "twitterbot", "slackbot", "slack-imgproxy", "discordbot",
+	"telegrambot", "whatsapp/", "linkedinbot", "skypeuripreview", "vkshare", "pinterestbot", "redditbot",
+	"embedly", "iframely",
+}
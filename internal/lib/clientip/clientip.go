@@ -0,0 +1,92 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// определяем ip клиента за прокси
+// X-Forwarded-For учитывается, только если запрос пришёл от доверенного прокси,
+// иначе клиент мог бы подставить любой адрес
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// trusted - адреса или подсети (CIDR) прокси, которым доверяем
+func New(trusted []string) (*Resolver, error) {
+	const op = "lib.clientip.New"
+
+	r := &Resolver{}
+	for _, s := range trusted {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+
+	return r, nil
+}
+
+// ip клиента: идём по X-Forwarded-For справа налево, пока адреса принадлежат доверенным прокси
+// если ip не удалось определить, возвращается невалидный netip.Addr
+func (r *Resolver) IP(req *http.Request) netip.Addr {
+	addr := remoteAddr(req.RemoteAddr)
+	if r == nil || !r.isTrusted(addr) {
+		return addr
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !r.isTrusted(addr) {
+			break
+		}
+	}
+
+	return addr
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remoteAddr(remote string) netip.Addr {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap()
+}
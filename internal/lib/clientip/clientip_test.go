@@ -0,0 +1,66 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	r, err := New([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{
+			name:   "No proxy",
+			remote: "203.0.113.7:5000",
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "Untrusted remote ignores header",
+			remote: "203.0.113.7:5000",
+			xff:    "198.51.100.1",
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "Trusted proxy",
+			remote: "10.1.2.3:5000",
+			xff:    "198.51.100.1",
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "Spoofed first hop",
+			remote: "192.168.1.1:5000",
+			xff:    "1.1.1.1, 198.51.100.1, 10.0.0.5",
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "Garbage in header",
+			remote: "10.1.2.3:5000",
+			xff:    "unknown",
+			want:   "10.1.2.3",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remote
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+
+			assert.Equal(t, tc.want, r.IP(req).String())
+		})
+	}
+
+	_, err = New([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// страна по ip из локального файла базы в формате MaxMind (GeoLite2-Country, GeoIP2-City и т.п.)
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// из записи базы нужен только код страны
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+func Open(path string) (*DB, error) {
+	const op = "lib.geoip.Open"
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &DB{reader: reader}, nil
+}
+
+func (d *DB) Close() error {
+	return d.reader.Close()
+}
+
+// ISO код страны (RU, US, ...), пустая строка - адреса нет в базе
+func (d *DB) Country(ip netip.Addr) (string, error) {
+	const op = "lib.geoip.Country"
+
+	if !ip.IsValid() {
+		return "", nil
+	}
+
+	var rec record
+	if err := d.reader.Lookup(ip.AsSlice(), &rec); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// у части адресов известна только страна регистрации сети
+	if rec.Country.ISOCode != "" {
+		return rec.Country.ISOCode, nil
+	}
+
+	return rec.RegisteredCountry.ISOCode, nil
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// набор проверок готовности сервиса (хранилище, миграции, фоновые воркеры)
+// воркеры регистрируют свою проверку при запуске
+
+type Check func(ctx context.Context) error
+
+type Checker struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Check
+
+	// сервис останавливается и больше не должен получать трафик
+	draining atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// добавляет или заменяет проверку с таким именем
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// после вызова готовность всегда отрицательная
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// результат одной проверки, ошибка пустая если проверка прошла
+type Result struct {
+	Name string
+	Err  error
+}
+
+// выполняет все проверки по порядку добавления
+func (c *Checker) Run(ctx context.Context) []Result {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, c.checks[name])
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, 0, len(names))
+	for i, check := range checks {
+		results = append(results, Result{Name: names[i], Err: check(ctx)})
+	}
+
+	return results
+}
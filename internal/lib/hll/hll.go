@@ -0,0 +1,87 @@
+package hll
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog: оценка числа разных значений по их 64-битным хэшам в фиксированном объёме памяти
+// 2^12 регистров по байту - 4 КБ на скетч, стандартная ошибка около 1.6%
+const (
+	precision = 12
+	registers = 1 << precision
+)
+
+var ErrInvalidSketch = errors.New("invalid hll sketch")
+
+type Sketch struct {
+	registers [registers]uint8
+}
+
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// восстанавливаем скетч, сохранённый через Bytes
+func FromBytes(data []byte) (*Sketch, error) {
+	if len(data) != 1+registers || data[0] != precision {
+		return nil, ErrInvalidSketch
+	}
+
+	s := &Sketch{}
+	copy(s.registers[:], data[1:])
+
+	return s, nil
+}
+
+// первый байт - точность, чтобы её можно было поменять, не путая старые скетчи с новыми
+func (s *Sketch) Bytes() []byte {
+	data := make([]byte, 1+registers)
+	data[0] = precision
+	copy(data[1:], s.registers[:])
+
+	return data
+}
+
+// hash должен быть равномерно распределён (например, часть sha256)
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - precision)
+	// единица в конце ограничивает длину серии нулей, если остаток хэша нулевой
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1)) + 1)
+
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// объединение: оценка числа разных значений, добавленных в любой из скетчей
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+}
+
+func (s *Sketch) Count() uint64 {
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// на малых количествах точнее linear counting по пустым регистрам
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
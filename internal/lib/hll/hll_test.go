@@ -0,0 +1,49 @@
+package hll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hash(v string) uint64 {
+	sum := sha256.Sum256([]byte(v))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestSketch(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add(hash(strconv.Itoa(i)))
+			// повторы не меняют оценку
+			s.Add(hash(strconv.Itoa(i)))
+		}
+
+		assert.InDelta(t, n, s.Count(), float64(n)*0.05+1, "n = %d", n)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 6000; i++ {
+		a.Add(hash(strconv.Itoa(i)))
+	}
+	// половина значений общая
+	for i := 3000; i < 9000; i++ {
+		b.Add(hash(strconv.Itoa(i)))
+	}
+
+	restored, err := FromBytes(a.Bytes())
+	require.NoError(t, err)
+	restored.Merge(b)
+
+	assert.InDelta(t, 9000, restored.Count(), 9000*0.05)
+
+	_, err = FromBytes([]byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidSketch)
+}
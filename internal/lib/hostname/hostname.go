@@ -0,0 +1,16 @@
+package hostname
+
+import (
+	"net"
+	"strings"
+)
+
+// приводим host к виду, в котором хранятся домены: нижний регистр, без порта и точки в конце
+func Normalize(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -11,6 +11,8 @@ import (
 type Response struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
+	// код ошибки для клиентов, в отличие от текста не меняется
+	Code string `json:"code,omitempty"`
 }
 
 const (
@@ -18,6 +20,13 @@ const (
 	StatusError = "Error"
 )
 
+// коды ошибок, по которым клиенты отличают их от остальных
+const (
+	CodeURLExists      = "url_exists"
+	CodeURLNotFound    = "url_not_found"
+	CodeDomainNotFound = "domain_not_found"
+)
+
 func OK() Response {
 	return Response{
 		Status: StatusOK,
@@ -31,6 +40,15 @@ func Error(msg string) Response {
 	}
 }
 
+// ошибка с кодом, который клиент может проверить
+func ErrorCode(code string, msg string) Response {
+	return Response{
+		Status: StatusError,
+		Error:  msg,
+		Code:   code,
+	}
+}
+
 // в массив получаем список ошибок валидатора
 func ValidationError(errs validator.ValidationErrors) Response {
 	var errMsgs []string
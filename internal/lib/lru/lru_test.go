@@ -0,0 +1,51 @@
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](2)
+
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+
+	// "a" использовали последним, поэтому вытеснится "b"
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	c.Set("c", 3, 0)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_TTL(t *testing.T) {
+	c := New[string, int](10)
+
+	c.Set("a", 1, time.Millisecond)
+	c.Set("b", 2, time.Hour)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	c.Remove("b")
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"time"
+
+	"url-shortener/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// все метрики приложения, отдаются на /metrics в формате prometheus
+
+const namespace = "url_shortener"
+
+var (
+	// route - шаблон роута chi (/{alias}), а не сам путь, чтобы алиасы не раздували количество рядов
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Storage operation latency.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op"})
+
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Total number of failed storage operations.",
+	}, []string{"op"})
+
+	AliasCollisions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alias_collisions_total",
+		Help:      "Total number of generated aliases that already existed.",
+	})
+
+	// result: found, not_found, not_active, expired, exhausted, password_required, preview, unfurl, error
+	Redirects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Total number of redirect lookups by result.",
+	}, []string{"result"})
+)
+
+// замеряем операцию с хранилищем, "не найден", "уже существует" и т.п. ошибками не считаем
+func ObserveStorage(op string, start time.Time, err error) {
+	StorageDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+
+	if err != nil && !storage.IsExpected(err) {
+		StorageErrors.WithLabelValues(op).Inc()
+	}
+}
+
+// регистрирует счётчик объединённых запросов редиректа
+func RegisterCoalesced(fn func() uint64) {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirect_coalesced_total",
+		Help:      "Total number of redirect lookups served by another in-flight lookup.",
+	}, func() float64 { return float64(fn()) })
+}
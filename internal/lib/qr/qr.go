@@ -0,0 +1,128 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrUnknownLevel  = errors.New("unknown error correction level")
+	ErrSizeTooSmall  = errors.New("size is too small for this content")
+)
+
+// уровни коррекции ошибок: сколько кода можно повредить (L ~7%, M ~15%, Q ~25%, H ~30%)
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Size - ширина и высота картинки в пикселях
+// Margin - белая рамка вокруг кода в модулях (стандарт требует 4)
+type Options struct {
+	Format string
+	Size   int
+	Level  string
+	Margin int
+}
+
+func ValidLevel(level string) bool {
+	_, ok := levels[level]
+	return ok
+}
+
+// рисуем qr код content в png или svg
+func Encode(content string, opts Options) ([]byte, error) {
+	const op = "lib.qr.Encode"
+
+	level, ok := levels[opts.Level]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnknownLevel)
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// рамку рисуем сами, у библиотеки она всегда 4 модуля
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	switch opts.Format {
+	case FormatPNG:
+		data, err := renderPNG(bitmap, opts.Size, opts.Margin)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return data, nil
+	case FormatSVG:
+		return renderSVG(bitmap, opts.Size, opts.Margin), nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", op, ErrUnknownFormat)
+}
+
+// модуль - целое число пикселей, иначе код плохо читается,
+// поэтому пиксели, которые не делятся на модули, добавляются к рамке
+func renderPNG(bitmap [][]bool, size int, margin int) ([]byte, error) {
+	modules := len(bitmap) + 2*margin
+	scale := size / modules
+	if scale < 1 {
+		return nil, ErrSizeTooSmall
+	}
+	offset := (size - len(bitmap)*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// svg масштабируется без потерь, size задаёт только размер по умолчанию
+func renderSVG(bitmap [][]bool, size int, margin int) []byte {
+	modules := len(bitmap) + 2*margin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return []byte(b.String())
+}
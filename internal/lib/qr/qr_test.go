@@ -0,0 +1,44 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePNG(t *testing.T) {
+	data, err := Encode("https://sho.rt/tg", Options{Format: FormatPNG, Size: 300, Level: "M", Margin: 4})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// угол - рамка, белый
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+}
+
+func TestEncodeSVG(t *testing.T) {
+	data, err := Encode("https://sho.rt/tg", Options{Format: FormatSVG, Size: 256, Level: "L", Margin: 2})
+	require.NoError(t, err)
+
+	// версия 1 (21x21 модуль) и рамка по 2 модуля с каждой стороны
+	assert.Contains(t, string(data), `viewBox="0 0 25 25"`)
+	assert.Contains(t, string(data), `width="256"`)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	_, err := Encode("https://sho.rt/tg", Options{Format: FormatPNG, Size: 256, Level: "X"})
+	assert.ErrorIs(t, err, ErrUnknownLevel)
+
+	_, err = Encode("https://sho.rt/tg", Options{Format: "gif", Size: 256, Level: "M"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Encode("https://sho.rt/tg", Options{Format: FormatPNG, Size: 10, Level: "M", Margin: 4})
+	assert.ErrorIs(t, err, ErrSizeTooSmall)
+}
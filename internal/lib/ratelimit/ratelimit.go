@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"url-shortener/internal/lib/lru"
+)
+
+// ограничение числа попыток по ключу в фиксированном окне времени
+// ключи хранятся в LRU, поэтому память ограничена, а давно не использованные ключи вытесняются
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	buckets *lru.Cache[string, *bucket]
+}
+
+type bucket struct {
+	count int
+	reset time.Time
+}
+
+func New(limit int, window time.Duration, size int) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  window,
+		buckets: lru.New[string, *bucket](size),
+	}
+}
+
+// засчитываем попытку, если лимит исчерпан - возвращаем false и через сколько можно повторить
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	b, ok := l.buckets.Get(key)
+	if !ok || !now.Before(b.reset) {
+		b = &bucket{reset: now.Add(l.window)}
+		l.buckets.Set(key, b, l.window)
+	}
+
+	if b.count >= l.limit {
+		return false, b.reset.Sub(now)
+	}
+	b.count++
+
+	return true, 0
+}
+
+// сбрасываем счётчик, например после успешной попытки
+func (l *Limiter) Reset(key string) {
+	l.buckets.Remove(key)
+}
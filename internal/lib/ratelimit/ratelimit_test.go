@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(2, 50*time.Millisecond, 10)
+
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	ok, retryAfter := l.Allow("a")
+	assert.False(t, ok)
+	assert.Positive(t, retryAfter)
+
+	// у другого ключа свой лимит
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	// после окна попытки снова разрешены
+	time.Sleep(60 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	l.Reset("b")
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+}
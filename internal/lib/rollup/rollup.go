@@ -0,0 +1,69 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+var ErrNotRunning = errors.New("clicks rollup is not running")
+
+// интерфейс для свёртки старых переходов в дневные агрегаты
+type Roller interface {
+	RollupClicks(ctx context.Context, before time.Time) (int64, error)
+}
+
+// фоновая свёртка переходов старше retention
+type Worker struct {
+	roller    Roller
+	retention time.Duration
+	interval  time.Duration
+
+	running atomic.Bool
+}
+
+func New(roller Roller, retention time.Duration, interval time.Duration) *Worker {
+	return &Worker{roller: roller, retention: retention, interval: interval}
+}
+
+// сразу и затем раз в interval сворачивает переходы старше retention, пока не отменён ctx
+// ошибка только логируется, переходы свернутся при следующем запуске
+func (w *Worker) Run(ctx context.Context, log *slog.Logger) {
+	log = log.With(slog.String("component", "rollup"))
+
+	w.running.Store(true)
+	defer w.running.Store(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.roller.RollupClicks(ctx, time.Now().Add(-w.retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to roll up clicks", sl.Err(err))
+		case n > 0:
+			log.Info("clicks rolled up", slog.Int64("clicks", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// проверка готовности: воркер запущен
+// ошибка отдельной свёртки на готовность не влияет, переходы свернутся при следующем запуске
+func (w *Worker) Check(context.Context) error {
+	if !w.running.Load() {
+		return ErrNotRunning
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package rollup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+type rollerFunc func(ctx context.Context, before time.Time) (int64, error)
+
+func (f rollerFunc) RollupClicks(ctx context.Context, before time.Time) (int64, error) {
+	return f(ctx, before)
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := make(chan time.Time, 10)
+	roller := rollerFunc(func(_ context.Context, before time.Time) (int64, error) {
+		calls <- before
+		return 1, nil
+	})
+
+	w := New(roller, 24*time.Hour, 10*time.Millisecond)
+	assert.ErrorIs(t, w.Check(ctx), ErrNotRunning)
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, slogdiscard.NewDiscardLogger())
+		close(done)
+	}()
+
+	// первая свёртка сразу после запуска, граница - retention назад
+	select {
+	case before := <-calls:
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+	case <-time.After(time.Second):
+		t.Fatal("rollup was not called")
+	}
+
+	// и дальше по таймеру
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("rollup was not repeated")
+	}
+	assert.NoError(t, w.Check(ctx))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+	assert.ErrorIs(t, w.Check(context.Background()), ErrNotRunning)
+}
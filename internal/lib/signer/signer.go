@@ -0,0 +1,58 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// подписывает значение со сроком действия (hmac-sha256), например для cookie
+// формат токена: value.expires.signature
+type Signer struct {
+	secret []byte
+}
+
+func New(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+func (s *Signer) Sign(value string, expires time.Time) string {
+	payload := value + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	return payload + "." + s.mac(payload)
+}
+
+// проверяем подпись и срок действия, возвращаем подписанное значение
+func (s *Signer) Verify(token string, now time.Time) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, sig := token[:i], token[i+1:]
+
+	if !hmac.Equal([]byte(sig), []byte(s.mac(payload))) {
+		return "", false
+	}
+
+	j := strings.LastIndexByte(payload, '.')
+	if j < 0 {
+		return "", false
+	}
+
+	expires, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return "", false
+	}
+
+	return payload[:j], true
+}
+
+func (s *Signer) mac(payload string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
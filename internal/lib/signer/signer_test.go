@@ -0,0 +1,31 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	s := New([]byte("secret"))
+	now := time.Now()
+
+	token := s.Sign("42", now.Add(time.Minute))
+
+	value, ok := s.Verify(token, now)
+	assert.True(t, ok)
+	assert.Equal(t, "42", value)
+
+	// срок действия истёк
+	_, ok = s.Verify(token, now.Add(2*time.Minute))
+	assert.False(t, ok)
+
+	// подпись другим ключом
+	_, ok = New([]byte("other")).Verify(token, now)
+	assert.False(t, ok)
+
+	// значение подменили
+	_, ok = s.Verify("43"+token[2:], now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,197 @@
+package tlsconf
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// настройки TLS для встроенного сервера и перезагрузка сертификата при изменении файлов
+
+var ErrNotRunning = errors.New("certificate reloader is not running")
+
+type Options struct {
+	// "1.2" или "1.3"
+	MinVersion string
+	// имена наборов шифров из crypto/tls (для TLS 1.2), пусто - наборы по умолчанию
+	CipherSuites []string
+}
+
+// собирает tls.Config, сертификат берётся из getCertificate при каждом рукопожатии
+func New(opts Options, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	const op = "lib.tlsconf.New"
+
+	minVersion, err := parseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	suites, err := parseCipherSuites(opts.CipherSuites)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: getCertificate,
+		// h2 первым, чтобы клиенты с поддержкой HTTP/2 выбирали его
+		NextProtos: []string{"h2", "http/1.1"},
+	}, nil
+}
+
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, fmt.Errorf("unsupported tls version %q", v)
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// держит текущий сертификат и перечитывает его, когда меняются файлы
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	modTime time.Time
+	lastErr error
+
+	cert    atomic.Pointer[tls.Certificate]
+	running atomic.Bool
+}
+
+// загружает сертификат, ошибка если файлы не читаются или не подходят друг к другу
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	const op = "lib.tlsconf.NewReloader"
+
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r, nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// раз в interval проверяет время изменения файлов, пока не отменён ctx
+// при ошибке продолжает отдавать предыдущий сертификат
+// interval <= 0 - перезагрузка отключена, Run сразу возвращается
+func (r *Reloader) Run(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	log = log.With(slog.String("component", "tlsconf/reloader"))
+
+	r.running.Store(true)
+	defer r.running.Store(false)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				log.Error("failed to reload certificate", sl.Err(err))
+				continue
+			}
+			if reloaded {
+				log.Info("certificate reloaded", slog.String("cert_file", r.certFile))
+			}
+		}
+	}
+}
+
+// проверка готовности: воркер запущен и последняя перезагрузка успешна
+func (r *Reloader) Check(context.Context) error {
+	if !r.running.Load() {
+		return ErrNotRunning
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastErr
+}
+
+func (r *Reloader) reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		r.lastErr = err
+		return false, err
+	}
+	if !modTime.After(r.modTime) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		r.lastErr = err
+		return false, err
+	}
+
+	r.cert.Store(&cert)
+	r.modTime = modTime
+	r.lastErr = nil
+
+	return true, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
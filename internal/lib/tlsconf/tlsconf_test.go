@@ -0,0 +1,113 @@
+package tlsconf
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+// пишет самоподписанный сертификат для host в certFile/keyFile
+func writeCert(t *testing.T, certFile, keyFile, host string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	now := time.Now()
+	writeCert(t, certFile, keyFile, "old.example.com", now.Add(-time.Minute))
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "old.example.com", commonName(t, r))
+	assert.ErrorIs(t, r.Check(context.Background()), ErrNotRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, slogdiscard.NewDiscardLogger(), 5*time.Millisecond)
+
+	writeCert(t, certFile, keyFile, "new.example.com", now)
+
+	assert.Eventually(t, func() bool {
+		return commonName(t, r) == "new.example.com"
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, r.Check(context.Background()))
+}
+
+func TestNew(t *testing.T) {
+	cfg, err := New(Options{
+		MinVersion:   "1.2",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+
+	_, err = New(Options{MinVersion: "1.0"}, nil)
+	assert.Error(t, err)
+
+	_, err = New(Options{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestReloaderDisabled(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "old.example.com", time.Now())
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	// нулевой интервал отключает перезагрузку, а не роняет процесс
+	for _, interval := range []time.Duration{0, -time.Second} {
+		r.Run(context.Background(), slogdiscard.NewDiscardLogger(), interval)
+	}
+	assert.Equal(t, "old.example.com", commonName(t, r))
+}
@@ -0,0 +1,98 @@
+package useragent
+
+import "strings"
+
+// системы
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+)
+
+// классы устройств
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// семейства браузеров
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserSafari  = "safari"
+	BrowserEdge    = "edge"
+	BrowserOpera   = "opera"
+	BrowserSamsung = "samsung"
+	BrowserYandex  = "yandex"
+)
+
+// что удалось понять по User-Agent, пустые OS и Browser - неизвестно
+// ботов определяет пакет botdetect
+type Info struct {
+	OS      string
+	Device  string
+	Browser string
+}
+
+// разбираем User-Agent по известным подстрокам, без полного разбора версий браузеров
+func Parse(ua string) Info {
+	s := strings.ToLower(ua)
+
+	info := Info{Device: DeviceDesktop, Browser: browser(s)}
+
+	switch {
+	case strings.Contains(s, "ipad"):
+		info.OS, info.Device = OSiOS, DeviceTablet
+	case strings.Contains(s, "iphone") || strings.Contains(s, "ipod"):
+		info.OS, info.Device = OSiOS, DeviceMobile
+	case strings.Contains(s, "android"):
+		// планшеты на android не пишут Mobile
+		info.OS, info.Device = OSAndroid, DeviceTablet
+		if strings.Contains(s, "mobile") {
+			info.Device = DeviceMobile
+		}
+	case strings.Contains(s, "windows phone"):
+		info.OS, info.Device = OSWindows, DeviceMobile
+	case strings.Contains(s, "windows"):
+		info.OS = OSWindows
+	case strings.Contains(s, "cros"):
+		info.OS = OSChromeOS
+	case strings.Contains(s, "macintosh") || strings.Contains(s, "mac os x"):
+		info.OS = OSMacOS
+	case strings.Contains(s, "linux"):
+		info.OS = OSLinux
+	}
+
+	if info.OS == "" && strings.Contains(s, "mobile") {
+		info.Device = DeviceMobile
+	}
+
+	return info
+}
+
+// семейство браузера, остальные браузеры на chromium пишут Chrome и Safari,
+// поэтому их проверяем раньше, а Chrome раньше Safari
+func browser(s string) string {
+	switch {
+	case strings.Contains(s, "edg/") || strings.Contains(s, "edga/") || strings.Contains(s, "edgios/"):
+		return BrowserEdge
+	case strings.Contains(s, "opr/") || strings.Contains(s, "opera"):
+		return BrowserOpera
+	case strings.Contains(s, "samsungbrowser/"):
+		return BrowserSamsung
+	case strings.Contains(s, "yabrowser/"):
+		return BrowserYandex
+	case strings.Contains(s, "firefox/") || strings.Contains(s, "fxios/"):
+		return BrowserFirefox
+	case strings.Contains(s, "chrome/") || strings.Contains(s, "crios/"):
+		return BrowserChrome
+	case strings.Contains(s, "safari/") && strings.Contains(s, "version/"):
+		return BrowserSafari
+	}
+
+	return ""
+}
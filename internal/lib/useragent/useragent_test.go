@@ -0,0 +1,72 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		ua   string
+		want Info
+	}{
+		{
+			name: "iPhone",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want: Info{OS: OSiOS, Device: DeviceMobile, Browser: BrowserSafari},
+		},
+		{
+			name: "iPad",
+			ua:   "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			want: Info{OS: OSiOS, Device: DeviceTablet, Browser: BrowserSafari},
+		},
+		{
+			name: "Android phone",
+			ua:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			want: Info{OS: OSAndroid, Device: DeviceMobile, Browser: BrowserChrome},
+		},
+		{
+			name: "Android tablet",
+			ua:   "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: Info{OS: OSAndroid, Device: DeviceTablet, Browser: BrowserChrome},
+		},
+		{
+			name: "Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: Info{OS: OSWindows, Device: DeviceDesktop, Browser: BrowserChrome},
+		},
+		{
+			name: "macOS",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			want: Info{OS: OSMacOS, Device: DeviceDesktop, Browser: BrowserSafari},
+		},
+		{
+			name: "Edge",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.80",
+			want: Info{OS: OSWindows, Device: DeviceDesktop, Browser: BrowserEdge},
+		},
+		{
+			name: "Firefox on Linux",
+			ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want: Info{OS: OSLinux, Device: DeviceDesktop, Browser: BrowserFirefox},
+		},
+		{
+			name: "Googlebot",
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: Info{Device: DeviceDesktop},
+		},
+		{
+			name: "Empty",
+			ua:   "",
+			want: Info{Device: DeviceDesktop},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Parse(tc.ua))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package utm
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// параметры, которые заполняются из шаблона
+var params = []struct {
+	key   string
+	value func(t storage.UTMTemplate) string
+}{
+	{"utm_source", func(t storage.UTMTemplate) string { return t.Source }},
+	{"utm_medium", func(t storage.UTMTemplate) string { return t.Medium }},
+	{"utm_campaign", func(t storage.UTMTemplate) string { return t.Campaign }},
+	{"utm_content", func(t storage.UTMTemplate) string { return t.Content }},
+}
+
+// добавляем к url параметры из шаблона
+// параметр, который уже есть в url, не меняется: ссылка может уточнить шаблон
+func Apply(rawURL string, t storage.UTMTemplate) (string, error) {
+	const op = "lib.utm.Apply"
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := u.Query()
+	for _, p := range params {
+		if v := p.value(t); v != "" && !q.Has(p.key) {
+			q.Set(p.key, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// значения для подстановок, которые вычисляются в момент перехода
+type Vars struct {
+	Alias string
+	Host  string
+	Now   time.Time
+}
+
+// подставляем значения вместо {date}, {year}, {month}, {alias} и {host} в utm_* параметрах url
+func Expand(rawURL string, vars Vars) (string, error) {
+	const op = "lib.utm.Expand"
+
+	if !strings.Contains(rawURL, "%7B") && !strings.Contains(rawURL, "{") {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := vars.Now.UTC()
+	replacer := strings.NewReplacer(
+		"{date}", now.Format(time.DateOnly),
+		"{year}", now.Format("2006"),
+		"{month}", now.Format("01"),
+		"{alias}", vars.Alias,
+		"{host}", vars.Host,
+	)
+
+	q := u.Query()
+	for key, values := range q {
+		if !strings.HasPrefix(key, "utm_") {
+			continue
+		}
+		for i, v := range values {
+			values[i] = replacer.Replace(v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
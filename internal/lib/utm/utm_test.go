@@ -0,0 +1,53 @@
+package utm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+)
+
+func TestApply(t *testing.T) {
+	tmpl := storage.UTMTemplate{Source: "telegram", Medium: "social", Campaign: "spring_{date}"}
+
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "Empty query",
+			url:  "https://example.org/page",
+			want: "https://example.org/page?utm_campaign=spring_%7Bdate%7D&utm_medium=social&utm_source=telegram",
+		},
+		{
+			name: "Link overrides template",
+			url:  "https://example.org/page?utm_source=vk&id=1",
+			want: "https://example.org/page?id=1&utm_campaign=spring_%7Bdate%7D&utm_medium=social&utm_source=vk",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(tc.url, tmpl)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := Vars{Alias: "tg", Host: "go.example.com", Now: time.Date(2024, 3, 5, 23, 0, 0, 0, time.UTC)}
+
+	got, err := Expand("https://example.org/page?utm_campaign=spring_%7Bdate%7D&utm_content=%7Balias%7D&q=%7Bdate%7D", vars)
+	require.NoError(t, err)
+	// подстановки работают только в utm_* параметрах
+	assert.Equal(t, "https://example.org/page?q=%7Bdate%7D&utm_campaign=spring_2024-03-05&utm_content=tg", got)
+
+	got, err = Expand("https://example.org/page?utm_source=telegram", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/page?utm_source=telegram", got)
+}
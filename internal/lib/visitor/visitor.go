@@ -0,0 +1,67 @@
+package visitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// хранилище соли на день, соль прошлых дней удаляется,
+// поэтому идентификаторы разных дней нельзя сопоставить
+type SaltStore interface {
+	VisitorSalt(ctx context.Context, day string) ([]byte, error)
+}
+
+// хэш посетителя для подсчёта уникальных: ip и User-Agent с солью текущего дня (UTC)
+// сами ip и User-Agent нигде не сохраняются
+type Hasher struct {
+	store SaltStore
+
+	mu   sync.Mutex
+	day  string
+	salt []byte
+}
+
+func NewHasher(store SaltStore) *Hasher {
+	return &Hasher{store: store}
+}
+
+func (h *Hasher) Hash(ctx context.Context, ip netip.Addr, userAgent string, now time.Time) (uint64, error) {
+	const op = "lib.visitor.Hash"
+
+	salt, err := h.saltFor(ctx, now.UTC().Format(time.DateOnly))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sum := sha256.New()
+	sum.Write(salt)
+	sum.Write(ip.AsSlice())
+	// разделитель, чтобы границу между ip и User-Agent нельзя было сдвинуть
+	sum.Write([]byte{0})
+	sum.Write([]byte(userAgent))
+
+	return binary.BigEndian.Uint64(sum.Sum(nil)[:8]), nil
+}
+
+// соль запрашиваем из хранилища раз в день, чтобы у всех экземпляров сервиса она была одна
+func (h *Hasher) saltFor(ctx context.Context, day string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.day == day {
+		return h.salt, nil
+	}
+
+	salt, err := h.store.VisitorSalt(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	h.day, h.salt = day, salt
+
+	return salt, nil
+}
@@ -0,0 +1,47 @@
+package visitor
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type saltStore struct {
+	calls int
+}
+
+func (s *saltStore) VisitorSalt(_ context.Context, day string) ([]byte, error) {
+	s.calls++
+	return []byte("salt-" + day), nil
+}
+
+func TestHasher(t *testing.T) {
+	store := &saltStore{}
+	h := NewHasher(store)
+	ctx := context.Background()
+
+	ip := netip.MustParseAddr("203.0.113.7")
+	day := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	a, err := h.Hash(ctx, ip, "Mozilla/5.0", day)
+	require.NoError(t, err)
+	b, err := h.Hash(ctx, ip, "Mozilla/5.0", day.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+	// соль одного дня запрашивается один раз
+	assert.Equal(t, 1, store.calls)
+
+	other, err := h.Hash(ctx, ip, "curl/8.0", day)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, other)
+
+	// на следующий день у того же посетителя другой хэш
+	next, err := h.Hash(ctx, ip, "Mozilla/5.0", day.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, next)
+	assert.Equal(t, 2, store.calls)
+}
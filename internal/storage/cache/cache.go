@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"url-shortener/internal/lib/lru"
+	"url-shortener/internal/storage"
+)
+
+// кэширующая обёртка над хранилищем для редиректа
+// горячие ссылки отдаются из памяти, отсутствующие алиасы тоже кэшируются (на меньшее время)
+
+type URLGetter interface {
+	GetURL(ctx context.Context, host string, alias string) (storage.URL, error)
+}
+
+type Cache struct {
+	getter      URLGetter
+	lru         *lru.Cache[key, entry]
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	// поколение растёт при каждой инвалидации: загрузка, начатая до неё, могла прочитать
+	// старую версию ссылки (в том числе через общий запрос coalesce), такой результат не кэшируем
+	mu         sync.Mutex
+	generation uint64
+}
+
+// кэшируем по host запроса: один и тот же алиас на разных доменах - разные ссылки
+type key struct {
+	host  string
+	alias string
+}
+
+type entry struct {
+	url      storage.URL
+	notFound bool
+}
+
+func New(getter URLGetter, size int, ttl time.Duration, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		getter:      getter,
+		lru:         lru.New[key, entry](size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+func (c *Cache) GetURL(ctx context.Context, host string, alias string) (storage.URL, error) {
+	k := key{host: host, alias: alias}
+
+	if e, ok := c.lru.Get(k); ok {
+		if e.notFound {
+			return storage.URL{}, storage.ErrURLNotFound
+		}
+		return e.url, nil
+	}
+
+	generation := c.currentGeneration()
+
+	u, err := c.getter.GetURL(ctx, host, alias)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		if c.negativeTTL > 0 {
+			c.set(generation, k, entry{notFound: true}, c.negativeTTL)
+		}
+		return storage.URL{}, err
+	}
+	if err != nil {
+		// прочие ошибки не кэшируем
+		return storage.URL{}, err
+	}
+
+	c.set(generation, k, entry{url: u}, c.ttl)
+
+	return u, nil
+}
+
+func (c *Cache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// записываем результат загрузки, только если с её начала не было инвалидации
+func (c *Cache) set(generation uint64, k key, e entry, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	c.lru.Set(k, e, ttl)
+}
+
+// сбрасывает записи, вызывается хранилищем при сохранении, изменении или удалении url
+// host в кэше не всегда совпадает с доменом (незарегистрированные host попадают в общее пространство),
+// поэтому сбрасываем алиас на всех host, а при изменении самих доменов - весь кэш
+func (c *Cache) Invalidate(domain string, alias string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if alias == "" {
+		c.lru.Purge()
+		return
+	}
+
+	c.lru.RemoveFunc(func(k key) bool { return k.alias == alias })
+}
@@ -0,0 +1,128 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+	"url-shortener/internal/storage/coalesce"
+)
+
+// простое хранилище в памяти, считает обращения
+type fakeGetter struct {
+	urls  map[string]string
+	calls int
+}
+
+func (f *fakeGetter) GetURL(_ context.Context, host string, alias string) (storage.URL, error) {
+	f.calls++
+
+	u, ok := f.urls[host+"/"+alias]
+	if !ok {
+		return storage.URL{}, storage.ErrURLNotFound
+	}
+	return storage.URL{Domain: host, Alias: alias, URL: u}, nil
+}
+
+func TestCache_GetURL(t *testing.T) {
+	ctx := context.Background()
+	getter := &fakeGetter{urls: map[string]string{"go.brand-a.com/tg": "https://web.telegram.org"}}
+	c := cache.New(getter, 10, time.Minute, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		u, err := c.GetURL(ctx, "go.brand-a.com", "tg")
+		require.NoError(t, err)
+		assert.Equal(t, "https://web.telegram.org", u.URL)
+	}
+	assert.Equal(t, 1, getter.calls)
+
+	// промахи тоже кэшируются, другой host - другая запись
+	for i := 0; i < 3; i++ {
+		_, err := c.GetURL(ctx, "go.brand-b.com", "tg")
+		require.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+	assert.Equal(t, 2, getter.calls)
+
+	// после инвалидации алиаса идём в хранилище заново для всех host
+	getter.urls["go.brand-b.com/tg"] = "https://google.com"
+	c.Invalidate("go.brand-b.com", "tg")
+
+	u, err := c.GetURL(ctx, "go.brand-b.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", u.URL)
+
+	_, err = c.GetURL(ctx, "go.brand-a.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, 4, getter.calls)
+
+	// изменение домена сбрасывает весь кэш
+	c.Invalidate("go.brand-a.com", "")
+
+	_, err = c.GetURL(ctx, "go.brand-b.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, 5, getter.calls)
+}
+
+// хранилище, которое читает ссылку и ждёт release перед ответом
+type gatedGetter struct {
+	mu      sync.Mutex
+	url     string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedGetter) GetURL(_ context.Context, host string, alias string) (storage.URL, error) {
+	g.mu.Lock()
+	u := g.url
+	g.mu.Unlock()
+
+	g.started <- struct{}{}
+	<-g.release
+
+	return storage.URL{Domain: host, Alias: alias, URL: u}, nil
+}
+
+func (g *gatedGetter) setURL(u string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.url = u
+}
+
+func TestCache_InvalidateDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	getter := &gatedGetter{
+		url:     "https://web.telegram.org",
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	c := cache.New(coalesce.New(getter), 10, time.Minute, time.Minute)
+
+	done := make(chan storage.URL)
+	go func() {
+		u, err := c.GetURL(ctx, "go.brand-a.com", "tg")
+		assert.NoError(t, err)
+		done <- u
+	}()
+
+	// загрузка уже прочитала старую ссылку, в этот момент её меняют
+	<-getter.started
+	getter.setURL("https://google.com")
+	c.Invalidate("go.brand-a.com", "tg")
+	close(getter.release)
+
+	assert.Equal(t, "https://web.telegram.org", (<-done).URL)
+
+	// устаревший результат не должен остаться в кэше
+	go func() { <-getter.started }()
+
+	u, err := c.GetURL(ctx, "go.brand-a.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", u.URL)
+}
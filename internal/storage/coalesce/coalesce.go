@@ -0,0 +1,58 @@
+package coalesce
+
+import (
+	"context"
+	"sync/atomic"
+
+	"url-shortener/internal/storage"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// обёртка над хранилищем, которая объединяет одновременные запросы одного и того же алиаса:
+// в хранилище уходит один запрос, остальные ждут его результат
+
+type URLGetter interface {
+	GetURL(ctx context.Context, host string, alias string) (storage.URL, error)
+}
+
+type Group struct {
+	getter URLGetter
+	group  singleflight.Group
+
+	// сколько запросов получили результат чужого обращения к хранилищу
+	coalesced atomic.Uint64
+}
+
+func New(getter URLGetter) *Group {
+	return &Group{getter: getter}
+}
+
+func (g *Group) GetURL(ctx context.Context, host string, alias string) (storage.URL, error) {
+	executed := false
+
+	// запрос в хранилище общий, поэтому не должен отменяться вместе с запросом первого клиента,
+	// каждый клиент сам перестаёт ждать при отмене своего контекста
+	ch := g.group.DoChan(host+"/"+alias, func() (any, error) {
+		executed = true
+		return g.getter.GetURL(context.WithoutCancel(ctx), host, alias)
+	})
+
+	select {
+	case <-ctx.Done():
+		return storage.URL{}, ctx.Err()
+	case res := <-ch:
+		if !executed {
+			g.coalesced.Add(1)
+		}
+		if res.Err != nil {
+			return storage.URL{}, res.Err
+		}
+		return res.Val.(storage.URL), nil
+	}
+}
+
+// количество объединённых запросов с момента запуска
+func (g *Group) Coalesced() uint64 {
+	return g.coalesced.Load()
+}
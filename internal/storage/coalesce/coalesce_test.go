@@ -0,0 +1,54 @@
+package coalesce_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/coalesce"
+)
+
+// хранилище, которое отвечает только после закрытия release
+type slowGetter struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (g *slowGetter) GetURL(_ context.Context, host string, alias string) (storage.URL, error) {
+	g.calls.Add(1)
+	<-g.release
+	return storage.URL{Alias: alias, URL: "https://web.telegram.org"}, nil
+}
+
+func TestGroup_GetURL(t *testing.T) {
+	const n = 20
+
+	getter := &slowGetter{release: make(chan struct{})}
+	g := coalesce.New(getter)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			u, err := g.GetURL(context.Background(), "localhost", "tg")
+			require.NoError(t, err)
+			assert.Equal(t, "https://web.telegram.org", u.URL)
+		}()
+	}
+
+	// даём всем запросам встать в ожидание
+	time.Sleep(50 * time.Millisecond)
+	close(getter.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), getter.calls.Load())
+	assert.Equal(t, uint64(n-1), g.Coalesced())
+}
@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// сохраняем переход по ссылке
+// если у ссылки закончились переходы (max_clicks), переход не сохраняется и возвращается ErrURLExhausted
+func (s *Storage) SaveClick(ctx context.Context, c storage.Click) (err error) {
+	const op = "storage.sqlite.SaveClick"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.StmtContext(ctx, s.useClickStmt).ExecContext(ctx, c.URLID)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrURLExhausted
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.StmtContext(ctx, s.saveClickStmt).ExecContext(ctx, c.URLID, now, c.Variant, c.Bot, c.Referrer, c.Source, c.Browser)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	if c.Visitor != 0 {
+		if err := s.addVisitor(ctx, tx, c.URLID, now.Format(time.DateOnly), c.Visitor); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// статистика по алиасу: куда ведёт и сколько было переходов, в том числе по вариантам a/b теста,
+// считаются только переходы за период и класса из q
+func (s *Storage) GetStats(ctx context.Context, domain string, alias string, q storage.StatsQuery) (_ storage.Stats, err error) {
+	const op = "storage.sqlite.GetStats"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	botFrom, botTo := botRange(q.Class)
+	fromDay, toDay := dayRange(q.From, q.To)
+
+	stats := storage.Stats{Domain: domain, Alias: alias, Class: q.Class}
+	if !q.From.IsZero() {
+		stats.From = fromDay
+	}
+	if !q.To.IsZero() {
+		stats.To = toDay
+	}
+
+	var id, maxClicks, clicksUsed int64
+	err = s.getStatsStmt.QueryRowContext(ctx, botFrom, botTo, fromDay, toDay, domain, alias).Scan(&id, &stats.URL, &stats.Clicks, &maxClicks, &clicksUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Stats{}, storage.ErrURLNotFound
+		}
+		return storage.Stats{}, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	stats.RemainingClicks = remainingClicks(maxClicks, clicksUsed)
+
+	rows, err := s.variantStatsStmt.QueryContext(ctx, id, botFrom, botTo, fromDay, toDay)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variant string
+		var clicks int64
+		if err := rows.Scan(&variant, &clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s:scan row: %w", op, err)
+		}
+		if stats.Variants == nil {
+			stats.Variants = make(map[string]int64)
+		}
+		stats.Variants[variant] = clicks
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s:iterate rows: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// limit самых частых referrer, utm_source и браузеров переходов по алиасу, пустые значения не учитываются
+// считается и по сырым переходам, и по дневным агрегатам
+func (s *Storage) Breakdown(ctx context.Context, domain string, alias string, q storage.StatsQuery, limit int) (_ storage.Breakdown, err error) {
+	const op = "storage.sqlite.Breakdown"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	botFrom, botTo := botRange(q.Class)
+	fromDay, toDay := dayRange(q.From, q.To)
+
+	var b storage.Breakdown
+	for _, top := range []struct {
+		stmt *sql.Stmt
+		dst  *[]storage.Count
+	}{
+		{s.topReferrersStmt, &b.Referrers},
+		{s.topSourcesStmt, &b.Sources},
+		{s.topBrowsersStmt, &b.Browsers},
+	} {
+		*top.dst, err = topCounts(ctx, top.stmt, domain, alias, botFrom, botTo, fromDay, toDay, limit)
+		if err != nil {
+			return storage.Breakdown{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return b, nil
+}
+
+func topCounts(ctx context.Context, stmt *sql.Stmt, args ...any) ([]storage.Count, error) {
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("execute statement: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []storage.Count
+	for rows.Next() {
+		var c storage.Count
+		if err := rows.Scan(&c.Value, &c.Clicks); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// сворачиваем переходы раньше before в дневные агрегаты (click_daily) и удаляем их,
+// before округляется до начала дня (UTC), чтобы агрегат дня собирался целиком
+// возвращает число свёрнутых переходов
+func (s *Storage) RollupClicks(ctx context.Context, before time.Time) (_ int64, err error) {
+	const op = "storage.sqlite.RollupClicks"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	before = before.UTC().Truncate(24 * time.Hour)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s:begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.StmtContext(ctx, s.rollupClicksStmt).ExecContext(ctx, before); err != nil {
+		return 0, fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	res, err := tx.StmtContext(ctx, s.deleteRawStmt).ExecContext(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s:commit transaction: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// запрос самых частых значений колонки click_counts по алиасу, классу и дням
+func topQuery(column string) string {
+	return `
+		SELECT c.` + column + `, SUM(c.clicks) AS n FROM click_counts c
+		WHERE c.url_id = (SELECT id FROM url WHERE domain = ? AND alias = ?)
+			AND c.` + column + ` != '' AND c.bot IN (?, ?) AND c.day >= ? AND c.day <= ?
+		GROUP BY c.` + column + ` ORDER BY n DESC, c.` + column + ` LIMIT ?`
+}
+
+// дни с from по to в виде YYYY-MM-DD, нулевое время - без ограничения
+func dayRange(from time.Time, to time.Time) (string, string) {
+	fromDay, toDay := "", "9999-12-31"
+	if !from.IsZero() {
+		fromDay = from.UTC().Format(time.DateOnly)
+	}
+	if !to.IsZero() {
+		toDay = to.UTC().Format(time.DateOnly)
+	}
+
+	return fromDay, toDay
+}
+
+// значения колонки bot для класса переходов
+func botRange(class string) (int, int) {
+	switch class {
+	case storage.ClassHuman:
+		return 0, 0
+	case storage.ClassBot:
+		return 1, 1
+	}
+
+	return 0, 1
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// регистрируем домен для коротких ссылок
+func (s *Storage) SaveDomain(ctx context.Context, host string) (err error) {
+	const op = "storage.sqlite.SaveDomain"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	_, err = s.saveDomainStmt.ExecContext(ctx, host, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrDomainExists)
+		}
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	// запросы с этим host теперь ищут алиасы в домене, а не в общем пространстве
+	s.notify(host, "")
+
+	return nil
+}
+
+func (s *Storage) ListDomains(ctx context.Context) (_ []storage.Domain, err error) {
+	const op = "storage.sqlite.ListDomains"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	rows, err := s.listDomainsStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	domains := []storage.Domain{}
+	for rows.Next() {
+		var d storage.Domain
+		if err := rows.Scan(&d.Host, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s:scan row: %w", op, err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:iterate rows: %w", op, err)
+	}
+
+	return domains, nil
+}
+
+// удаляем домен, если в нём не осталось ссылок
+func (s *Storage) DeleteDomain(ctx context.Context, host string) (err error) {
+	const op = "storage.sqlite.DeleteDomain"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var one int
+	err = tx.StmtContext(ctx, s.domainInUseStmt).QueryRowContext(ctx, host).Scan(&one)
+	if err == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrDomainInUse)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+
+	res, err := tx.StmtContext(ctx, s.deleteDomainStmt).ExecContext(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrDomainNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:commit transaction: %w", op, err)
+	}
+
+	s.notify(host, "")
+
+	return nil
+}
+
+func (s *Storage) checkDomain(ctx context.Context, host string) error {
+	var one int
+	err := s.domainExistsStmt.QueryRowContext(ctx, host).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrDomainNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// миграции схемы БД, номер последней применённой хранится в PRAGMA user_version
+// новые миграции добавляются только в конец списка, уже применённые не меняются
+// url-shortener будет брать длинную ссылку, и делать из неё короткую, заменяя длинную часть на alias
+var migrations = []string{
+	// 1: таблица ссылок
+	`CREATE TABLE IF NOT EXISTS url(
+		id INTEGER PRIMARY KEY,
+		alias TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS ind_alias on url(alias);`,
+
+	// 2: таблица переходов по ссылкам, по ней считаем статистику
+	`CREATE TABLE IF NOT EXISTS click(
+		id INTEGER PRIMARY KEY,
+		alias TEXT NOT NULL,
+		created_at DATETIME NOT NULL);
+	CREATE INDEX IF NOT EXISTS ind_click_alias on click(alias);`,
+
+	// 3: домены, алиас уникален в пределах домена (пустой домен - общее пространство),
+	// переходы привязываются к id ссылки, а не к алиасу
+	`CREATE TABLE domain(
+		host TEXT PRIMARY KEY,
+		created_at DATETIME NOT NULL);
+
+	CREATE TABLE url_new(
+		id INTEGER PRIMARY KEY,
+		domain TEXT NOT NULL DEFAULT '',
+		alias TEXT NOT NULL,
+		url TEXT NOT NULL,
+		UNIQUE(domain, alias));
+	INSERT INTO url_new(id, alias, url) SELECT id, alias, url FROM url;
+	DROP TABLE url;
+	ALTER TABLE url_new RENAME TO url;
+
+	CREATE TABLE click_new(
+		id INTEGER PRIMARY KEY,
+		url_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL);
+	INSERT INTO click_new(id, url_id, created_at)
+		SELECT c.id, u.id, c.created_at FROM click c JOIN url u ON u.alias = c.alias;
+	DROP TABLE click;
+	ALTER TABLE click_new RENAME TO click;
+	CREATE INDEX ind_click_url_id ON click(url_id);`,
+
+	// 4: код ответа редиректа для ссылки, 0 - по умолчанию
+	`ALTER TABLE url ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 0;`,
+
+	// 5: передача параметров запроса и пути после алиаса в целевой url
+	`ALTER TABLE url ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE url ADD COLUMN forward_path INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE url ADD COLUMN query_conflict TEXT NOT NULL DEFAULT '';`,
+
+	// 6: шаблоны utm параметров
+	`CREATE TABLE utm_template(
+		name TEXT PRIMARY KEY,
+		source TEXT NOT NULL,
+		medium TEXT NOT NULL,
+		campaign TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME NOT NULL);
+	ALTER TABLE url ADD COLUMN utm_template TEXT NOT NULL DEFAULT '';`,
+
+	// 7: пароль на ссылку
+	`ALTER TABLE url ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';`,
+
+	// 8: ограничение числа переходов, clicks_used меняется вместе с записью перехода
+	`ALTER TABLE url ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE url ADD COLUMN clicks_used INTEGER NOT NULL DEFAULT 0;`,
+
+	// 9: время начала и окончания работы ссылки
+	`ALTER TABLE url ADD COLUMN active_from DATETIME;
+	ALTER TABLE url ADD COLUMN expires_at DATETIME;`,
+
+	// 10: запасной адрес для ссылки, которую нельзя открыть
+	`ALTER TABLE url ADD COLUMN fallback_url TEXT NOT NULL DEFAULT '';`,
+
+	// 11: название и время создания ссылки для страницы просмотра, у старых ссылок время неизвестно
+	`ALTER TABLE url ADD COLUMN title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN created_at DATETIME;`,
+
+	// 12: адреса ссылки по User-Agent (json список storage.Target)
+	`ALTER TABLE url ADD COLUMN targets TEXT NOT NULL DEFAULT '';`,
+
+	// 13: варианты a/b теста (json список storage.Variant) и вариант, на который ушёл переход
+	`ALTER TABLE url ADD COLUMN variants TEXT NOT NULL DEFAULT '';
+	ALTER TABLE click ADD COLUMN variant TEXT NOT NULL DEFAULT '';`,
+
+	// 14: уникальные посетители - hll скетч на ссылку и день (UTC),
+	// соль для хэша посетителя хранится только за текущий день
+	`CREATE TABLE unique_visitors(
+		url_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		sketch BLOB NOT NULL,
+		PRIMARY KEY(url_id, day));
+	CREATE TABLE visitor_salt(
+		day TEXT PRIMARY KEY,
+		salt BLOB NOT NULL);`,
+
+	// 15: переходы ботов, старые переходы считаются переходами людей
+	`ALTER TABLE click ADD COLUMN bot INTEGER NOT NULL DEFAULT 0;`,
+
+	// 16: источники переходов и дневные агрегаты, в которые сворачиваются старые переходы,
+	// статистика читается из click_counts - сырые переходы вместе с агрегатами
+	`ALTER TABLE click ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
+	ALTER TABLE click ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+	ALTER TABLE click ADD COLUMN browser TEXT NOT NULL DEFAULT '';
+	CREATE INDEX ind_click_created_at ON click(created_at);
+	CREATE TABLE click_daily(
+		url_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		variant TEXT NOT NULL,
+		bot INTEGER NOT NULL,
+		referrer TEXT NOT NULL,
+		utm_source TEXT NOT NULL,
+		browser TEXT NOT NULL,
+		clicks INTEGER NOT NULL,
+		PRIMARY KEY(url_id, day, variant, bot, referrer, utm_source, browser));
+	CREATE VIEW click_counts AS
+		SELECT url_id, date(created_at) AS day, variant, bot, referrer, utm_source, browser, 1 AS clicks FROM click
+		UNION ALL
+		SELECT url_id, day, variant, bot, referrer, utm_source, browser, clicks FROM click_daily;`,
+}
+
+// применяем миграции, которых ещё нет в БД, каждую в своей транзакции
+func migrate(ctx context.Context, db *sql.DB) error {
+	const op = "storage.sqlite.migrate"
+
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("%s: begin migration %d: %w", op, i+1, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: apply migration %d: %w", op, i+1, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: set version %d: %w", op, i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: commit migration %d: %w", op, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// проверяем, что в БД применены все миграции, которые знает приложение
+func (s *Storage) CheckMigrations(ctx context.Context) error {
+	const op = "storage.sqlite.CheckMigrations"
+
+	version, err := schemaVersion(ctx, s.db)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if version < len(migrations) {
+		return fmt.Errorf("%s: schema version %d, expected %d", op, version, len(migrations))
+	}
+
+	return nil
+}
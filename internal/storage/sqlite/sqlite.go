@@ -1,116 +1,262 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 
 	"github.com/mattn/go-sqlite3" // init sqlite3 driver
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // реализация для sqlite
 
 type Storage struct {
 	db *sql.DB
+
+	// запросы подготавливаются один раз при создании хранилища и закрываются в Close
+	stmts []*sql.Stmt
+
+	saveURLStmt      *sql.Stmt
+	getURLStmt       *sql.Stmt
+	resolveURLStmt   *sql.Stmt
+	deleteURLStmt    *sql.Stmt
+	deleteClicksStmt *sql.Stmt
+	updateURLStmt    *sql.Stmt
+	updateWindowStmt *sql.Stmt
+	listURLsStmt     *sql.Stmt
+	saveClickStmt    *sql.Stmt
+	useClickStmt     *sql.Stmt
+	getStatsStmt     *sql.Stmt
+	variantStatsStmt *sql.Stmt
+
+	topReferrersStmt *sql.Stmt
+	topSourcesStmt   *sql.Stmt
+	topBrowsersStmt  *sql.Stmt
+	rollupClicksStmt *sql.Stmt
+	deleteRawStmt    *sql.Stmt
+	deleteDailyStmt  *sql.Stmt
+
+	getSketchStmt    *sql.Stmt
+	saveSketchStmt   *sql.Stmt
+	listSketchesStmt *sql.Stmt
+	deleteSketchStmt *sql.Stmt
+	getSaltStmt      *sql.Stmt
+	saveSaltStmt     *sql.Stmt
+	deleteSaltsStmt  *sql.Stmt
+
+	domainExistsStmt *sql.Stmt
+	saveDomainStmt   *sql.Stmt
+	listDomainsStmt  *sql.Stmt
+	deleteDomainStmt *sql.Stmt
+	domainInUseStmt  *sql.Stmt
+
+	saveUTMTemplateStmt   *sql.Stmt
+	getUTMTemplateStmt    *sql.Stmt
+	listUTMTemplatesStmt  *sql.Stmt
+	deleteUTMTemplateStmt *sql.Stmt
+
+	// вызываются после изменения url или домена (например, для сброса кэша)
+	hooks []func(domain, alias string)
+}
+
+// настройки подключения к БД
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// сколько ждать снятия блокировки БД другим соединением, прежде чем вернуть "database is locked"
+	BusyTimeout time.Duration
 }
 
-func New(storagePath string) (*Storage, error) {
+func New(storagePath string, opts Options) (*Storage, error) {
 	// константа нужна чтобы показывать место возникновения ошибки для дебага
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := sql.Open("sqlite3", dsn(storagePath, opts))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	//
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
 
-	// создаём таблицу если она не существует(миграции не предусмотрены)
-	// url-shortener будет брать длинную ссылку, и делать из неё короткую, заменяя длинную часть на alias
-	// Prepare возвращает подготовленный запрос
-	stmt, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS url(
-		id INTEGER PRIMARY KEY,
-		alias TEXT NOT NULL UNIQUE,
-		url TEXT NOT NULL);
-	CREATE INDEX IF NOT EXISTS ind_alias on url(alias)
-	`)
-	if err != nil {
+	if err := migrate(context.Background(), db); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// выполняем запрос
-	_, err = stmt.Exec()
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+	s := &Storage{db: db}
 
-	return &Storage{db: db}, nil
-}
+	// Prepare возвращает подготовленный запрос
+	stmts := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.saveURLStmt, "INSERT INTO url(" + urlColumns + ") VALUES(" + urlPlaceholders + ")"},
+		{&s.getURLStmt, "SELECT id, url FROM url WHERE domain = ? AND alias = ?"},
+		// домен берётся из host запроса, если такого домена нет - ищем в общем пространстве
+		{&s.resolveURLStmt, `
+		SELECT ` + urlSelectColumns + ` FROM url
+		WHERE alias = ? AND domain = COALESCE((SELECT host FROM domain WHERE host = ?), '')`},
+		{&s.deleteURLStmt, "DELETE FROM url WHERE id = ?"},
+		{&s.deleteClicksStmt, "DELETE FROM click WHERE url_id = ?"},
+		{&s.updateURLStmt, "UPDATE url SET url = ? WHERE domain = ? AND alias = ?"},
+		{&s.updateWindowStmt, "UPDATE url SET active_from = ?, expires_at = ? WHERE domain = ? AND alias = ?"},
+		{&s.listURLsStmt, `
+		SELECT ` + urlSelectColumns + ` FROM url
+		WHERE domain = ? ORDER BY id LIMIT ? OFFSET ?`},
+		{&s.saveClickStmt, `
+		INSERT INTO click(url_id, created_at, variant, bot, referrer, utm_source, browser)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`},
+		// условие и увеличение счётчика в одном запросе, поэтому лишних переходов не будет и при конкурентных запросах
+		{&s.useClickStmt, `
+		UPDATE url SET clicks_used = clicks_used + 1
+		WHERE id = ? AND (max_clicks = 0 OR clicks_used < max_clicks)`},
+		// переходы фильтруются по классу: bot IN (0, 1) - все, (0, 0) - люди, (1, 1) - боты
+		{&s.getStatsStmt, `
+		SELECT u.id, u.url,
+			(SELECT COALESCE(SUM(c.clicks), 0) FROM click_counts c
+			WHERE c.url_id = u.id AND c.bot IN (?, ?) AND c.day >= ? AND c.day <= ?),
+			u.max_clicks, u.clicks_used
+		FROM url u WHERE u.domain = ? AND u.alias = ?`},
+		{&s.variantStatsStmt, `
+		SELECT variant, SUM(clicks) FROM click_counts
+		WHERE url_id = ? AND variant != '' AND bot IN (?, ?) AND day >= ? AND day <= ? GROUP BY variant`},
+		{&s.topReferrersStmt, topQuery("referrer")},
+		{&s.topSourcesStmt, topQuery("utm_source")},
+		{&s.topBrowsersStmt, topQuery("browser")},
+		// переходы, которые уже есть в агрегатах, прибавляются к ним
+		{&s.rollupClicksStmt, `
+		INSERT INTO click_daily(url_id, day, variant, bot, referrer, utm_source, browser, clicks)
+		SELECT url_id, date(created_at), variant, bot, referrer, utm_source, browser, COUNT(*) FROM click
+		WHERE created_at < ?
+		GROUP BY url_id, date(created_at), variant, bot, referrer, utm_source, browser
+		ON CONFLICT(url_id, day, variant, bot, referrer, utm_source, browser) DO UPDATE SET clicks = clicks + excluded.clicks`},
+		{&s.deleteRawStmt, "DELETE FROM click WHERE created_at < ?"},
+		{&s.deleteDailyStmt, "DELETE FROM click_daily WHERE url_id = ?"},
+		{&s.getSketchStmt, "SELECT sketch FROM unique_visitors WHERE url_id = ? AND day = ?"},
+		{&s.saveSketchStmt, `
+		INSERT INTO unique_visitors(url_id, day, sketch) VALUES(?, ?, ?)
+		ON CONFLICT(url_id, day) DO UPDATE SET sketch = excluded.sketch`},
+		// дни хранятся как YYYY-MM-DD, поэтому сравнение строк совпадает со сравнением дат
+		{&s.listSketchesStmt, `
+		SELECT v.sketch FROM unique_visitors v JOIN url u ON u.id = v.url_id
+		WHERE u.domain = ? AND u.alias = ? AND v.day >= ? AND v.day <= ?`},
+		{&s.deleteSketchStmt, "DELETE FROM unique_visitors WHERE url_id = ?"},
+		{&s.getSaltStmt, "SELECT salt FROM visitor_salt WHERE day = ?"},
+		{&s.saveSaltStmt, "INSERT OR IGNORE INTO visitor_salt(day, salt) VALUES(?, ?)"},
+		{&s.deleteSaltsStmt, "DELETE FROM visitor_salt WHERE day < ?"},
 
-// int64 - это индекс созданной записи
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
-	const op = "storage.sqlite.SaveURL"
+		{&s.domainExistsStmt, "SELECT 1 FROM domain WHERE host = ?"},
+		{&s.saveDomainStmt, "INSERT INTO domain(host, created_at) VALUES(?, ?)"},
+		{&s.listDomainsStmt, "SELECT host, created_at FROM domain ORDER BY host"},
+		{&s.deleteDomainStmt, "DELETE FROM domain WHERE host = ?"},
+		{&s.domainInUseStmt, "SELECT 1 FROM url WHERE domain = ? LIMIT 1"},
 
-	// вставляем новую запись(новый url)
-	stmt, err := s.db.Prepare("INSERT INTO url(url, alias) VALUES(?, ?)")
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		{&s.saveUTMTemplateStmt, `
+		INSERT INTO utm_template(name, source, medium, campaign, content, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)`},
+		{&s.getUTMTemplateStmt, "SELECT name, source, medium, campaign, content, created_at FROM utm_template WHERE name = ?"},
+		{&s.listUTMTemplatesStmt, "SELECT name, source, medium, campaign, content, created_at FROM utm_template ORDER BY name"},
+		{&s.deleteUTMTemplateStmt, "DELETE FROM utm_template WHERE name = ?"},
 	}
-
-	res, err := stmt.Exec(urlToSave, alias)
-	if err != nil {
-		// проверка на ошибку, что введён алиас, который уже существует
-		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	for _, st := range stmts {
+		*st.stmt, err = db.Prepare(st.query)
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("%s: prepare %q: %w", op, st.query, err)
 		}
-		return 0, fmt.Errorf("%s: %w", op, err)
+		s.stmts = append(s.stmts, *st.stmt)
 	}
 
-	// возвращаем id
-	id, err := res.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("%s: failed to get last insert id: %w", op, err)
+	return s, nil
+}
+
+// строка подключения: WAL позволяет читать параллельно с записью,
+// busy_timeout - ждать блокировку вместо немедленной ошибки
+func dsn(storagePath string, opts Options) string {
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
 	}
 
-	return id, nil
+	return fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d", storagePath, sep, opts.BusyTimeout.Milliseconds())
 }
 
-// получаем url
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.sqlite.GetURL"
+// закрываем подготовленные запросы и соединение с БД
+func (s *Storage) Close() error {
+	const op = "storage.sqlite.Close"
 
-	stmt, err := s.db.Prepare("SELECT url FROM url WHERE alias = ?")
-	if err != nil {
-		return "", fmt.Errorf("%s:prepare statement: %w", op, err)
+	var errs []error
+	for _, stmt := range s.stmts {
+		errs = append(errs, stmt.Close())
 	}
+	errs = append(errs, s.db.Close())
 
-	var resURL string
-	err = stmt.QueryRow(alias).Scan(&resURL)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrURLNotFound
-		}
-		return "", fmt.Errorf("%s:execute statement: %w", op, err)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return resURL, nil
+	return nil
 }
 
-// удаляем url
-func (s *Storage) DeleteURL(alias string) error {
-	const op = "storage.sqlite.Delete"
+// проверяем, что БД доступна
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
 
-	stmt, err := s.db.Prepare("DELETE FROM url WHERE alias = ?")
-	if err != nil {
-		return fmt.Errorf("%s:prepare statement: %w", op, err)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec(alias)
-	if err != nil {
-		return fmt.Errorf("%s:exec statement: %w", op, err)
-	}
 	return nil
 }
+
+// регистрирует функцию, которая вызывается после сохранения, изменения или удаления url
+// и после добавления или удаления домена (тогда alias пустой)
+// регистрировать нужно до начала обработки запросов
+func (s *Storage) OnChange(fn func(domain, alias string)) {
+	s.hooks = append(s.hooks, fn)
+}
+
+func (s *Storage) notify(domain, alias string) {
+	for _, fn := range s.hooks {
+		fn(domain, alias)
+	}
+}
+
+var tracer = tracing.Tracer("storage")
+
+// открываем спан операции с хранилищем, возвращаемую функцию вызываем через defer
+// с указателем на ошибку: она закрывает спан и пишет время и ошибку операции в метрики
+func startOp(ctx context.Context, op string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, op, trace.WithAttributes(semconv.DBSystemSqlite))
+
+	return ctx, func(err *error) {
+		metrics.ObserveStorage(op, start, *err)
+
+		if *err != nil && !storage.IsExpected(*err) {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+// нарушение уникальности (алиас или домен уже есть)
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
@@ -0,0 +1,434 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/sqlite"
+)
+
+func newStorage(t *testing.T) *sqlite.Storage {
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{
+		MaxOpenConns: 10,
+		MaxIdleConns: 10,
+		BusyTimeout:  5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, s.Close()) })
+
+	return s
+}
+
+func TestStorage_URL(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	var changed []string
+	s.OnChange(func(domain, alias string) { changed = append(changed, alias) })
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://web.telegram.org", RedirectStatus: 308})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://google.com"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	require.NoError(t, s.UpdateURL(ctx, "", "tg", "https://google.com"))
+	require.ErrorIs(t, s.UpdateURL(ctx, "", "missing", "https://google.com"), storage.ErrURLNotFound)
+
+	u, err := s.GetURL(ctx, "", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+	assert.Equal(t, "https://google.com", u.URL)
+	assert.Equal(t, 308, u.RedirectStatus)
+
+	require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: u.ID}))
+
+	stats, err := s.GetStats(ctx, "", "tg", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Clicks)
+
+	require.NoError(t, s.DeleteURL(ctx, "", "tg"))
+	require.ErrorIs(t, s.DeleteURL(ctx, "", "tg"), storage.ErrURLNotFound)
+
+	_, err = s.GetURL(ctx, "", "tg")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	assert.Equal(t, []string{"tg", "tg", "tg"}, changed)
+}
+
+func TestStorage_Domain(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, storage.URL{Domain: "go.example.com", Alias: "tg", URL: "https://web.telegram.org"})
+	require.ErrorIs(t, err, storage.ErrDomainNotFound)
+
+	require.NoError(t, s.SaveDomain(ctx, "go.example.com"))
+	require.ErrorIs(t, s.SaveDomain(ctx, "go.example.com"), storage.ErrDomainExists)
+
+	// один и тот же алиас в разных доменах
+	_, err = s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://google.com"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, storage.URL{Domain: "go.example.com", Alias: "tg", URL: "https://web.telegram.org"})
+	require.NoError(t, err)
+
+	u, err := s.GetURL(ctx, "go.example.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://web.telegram.org", u.URL)
+
+	// незарегистрированный host ищет в общем пространстве
+	u, err = s.GetURL(ctx, "localhost", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", u.URL)
+
+	urls, err := s.ListURLs(ctx, "go.example.com", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "go.example.com", urls[0].Domain)
+
+	require.ErrorIs(t, s.DeleteDomain(ctx, "go.example.com"), storage.ErrDomainInUse)
+	require.NoError(t, s.DeleteURL(ctx, "go.example.com", "tg"))
+	require.NoError(t, s.DeleteDomain(ctx, "go.example.com"))
+	require.ErrorIs(t, s.DeleteDomain(ctx, "go.example.com"), storage.ErrDomainNotFound)
+
+	domains, err := s.ListDomains(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, domains)
+}
+
+func TestStorage_Concurrent(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://web.telegram.org"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.GetURL(ctx, "", "tg")
+			errs <- err
+			errs <- s.SaveClick(ctx, storage.Click{URLID: id})
+		}()
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.SaveURL(ctx, storage.URL{Alias: fmt.Sprintf("alias_%d", i), URL: "https://google.com"})
+			errs <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	stats, err := s.GetStats(ctx, "", "tg", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), stats.Clicks)
+}
+
+func TestStorage_UTMTemplate(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	tmpl := storage.UTMTemplate{Name: "spring", Source: "telegram", Campaign: "spring_{date}"}
+	require.NoError(t, s.SaveUTMTemplate(ctx, tmpl))
+	require.ErrorIs(t, s.SaveUTMTemplate(ctx, tmpl), storage.ErrUTMTemplateExists)
+
+	got, err := s.GetUTMTemplate(ctx, "spring")
+	require.NoError(t, err)
+	assert.Equal(t, "spring_{date}", got.Campaign)
+
+	templates, err := s.ListUTMTemplates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	require.NoError(t, s.DeleteUTMTemplate(ctx, "spring"))
+	require.ErrorIs(t, s.DeleteUTMTemplate(ctx, "spring"), storage.ErrUTMTemplateNotFound)
+
+	_, err = s.GetUTMTemplate(ctx, "spring")
+	require.ErrorIs(t, err, storage.ErrUTMTemplateNotFound)
+}
+
+func TestStorage_MaxClicks(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "once", URL: "https://web.telegram.org", MaxClicks: 10})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var used, exhausted atomic.Int64
+
+	// переходов больше, чем разрешено, лишние должны получить ErrURLExhausted
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.SaveClick(ctx, storage.Click{URLID: id})
+			switch {
+			case err == nil:
+				used.Add(1)
+			case errors.Is(err, storage.ErrURLExhausted):
+				exhausted.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), used.Load())
+	assert.Equal(t, int64(40), exhausted.Load())
+
+	stats, err := s.GetStats(ctx, "", "once", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), stats.Clicks)
+	require.NotNil(t, stats.RemainingClicks)
+	assert.Equal(t, int64(0), *stats.RemainingClicks)
+
+	u, err := s.GetURL(ctx, "", "once")
+	require.NoError(t, err)
+	require.NotNil(t, u.RemainingClicks)
+	assert.Equal(t, int64(0), *u.RemainingClicks)
+}
+
+func TestStorage_Window(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	launch := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	_, err := s.SaveURL(ctx, storage.URL{Alias: "launch", URL: "https://web.telegram.org", ActiveFrom: &launch})
+	require.NoError(t, err)
+
+	u, err := s.GetURL(ctx, "", "launch")
+	require.NoError(t, err)
+	require.NotNil(t, u.ActiveFrom)
+	assert.True(t, launch.Equal(*u.ActiveFrom))
+	assert.Nil(t, u.ExpiresAt)
+	assert.True(t, u.NotActiveYet(time.Now()))
+
+	expires := launch.Add(24 * time.Hour)
+	require.NoError(t, s.UpdateWindow(ctx, "", "launch", nil, &expires))
+	require.ErrorIs(t, s.UpdateWindow(ctx, "", "missing", nil, nil), storage.ErrURLNotFound)
+
+	u, err = s.GetURL(ctx, "", "launch")
+	require.NoError(t, err)
+	assert.Nil(t, u.ActiveFrom)
+	require.NotNil(t, u.ExpiresAt)
+	assert.True(t, u.Expired(expires))
+}
+
+func TestStorage_Targets(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	bot := false
+	targets := []storage.Target{
+		{OS: "ios", URL: "https://apps.apple.com/app/id1"},
+		{OS: "android", Device: "mobile", Bot: &bot, URL: "https://play.google.com/store/apps/details?id=org.example"},
+	}
+
+	_, err := s.SaveURL(ctx, storage.URL{Alias: "app", URL: "https://example.org", Targets: targets})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, storage.URL{Alias: "plain", URL: "https://example.org"})
+	require.NoError(t, err)
+
+	u, err := s.GetURL(ctx, "", "app")
+	require.NoError(t, err)
+	assert.Equal(t, targets, u.Targets)
+
+	u, err = s.GetURL(ctx, "", "plain")
+	require.NoError(t, err)
+	assert.Nil(t, u.Targets)
+}
+
+func TestStorage_Variants(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	variants := []storage.Variant{
+		{Name: "a", URL: "https://example.org/a", Weight: 70},
+		{Name: "b", URL: "https://example.org/b", Weight: 30},
+	}
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "ab", URL: "https://example.org", Variants: variants})
+	require.NoError(t, err)
+
+	u, err := s.GetURL(ctx, "", "ab")
+	require.NoError(t, err)
+	assert.Equal(t, variants, u.Variants)
+
+	for _, variant := range []string{"a", "a", "b", ""} {
+		require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: id, Variant: variant}))
+	}
+
+	stats, err := s.GetStats(ctx, "", "ab", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Clicks)
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, stats.Variants)
+}
+
+func TestStorage_ClickClass(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "ab", URL: "https://example.org", Variants: []storage.Variant{
+		{Name: "a", URL: "https://example.org/a", Weight: 1},
+		{Name: "b", URL: "https://example.org/b", Weight: 1},
+	}})
+	require.NoError(t, err)
+
+	for _, c := range []storage.Click{
+		{URLID: id, Variant: "a"},
+		{URLID: id, Variant: "a", Bot: true},
+		{URLID: id, Variant: "b", Bot: true},
+	} {
+		require.NoError(t, s.SaveClick(ctx, c))
+	}
+
+	for class, want := range map[string]storage.Stats{
+		"":                 {Clicks: 3, Variants: map[string]int64{"a": 2, "b": 1}},
+		storage.ClassHuman: {Clicks: 1, Variants: map[string]int64{"a": 1}},
+		storage.ClassBot:   {Clicks: 2, Variants: map[string]int64{"a": 1, "b": 1}},
+	} {
+		stats, err := s.GetStats(ctx, "", "ab", storage.StatsQuery{Class: class})
+		require.NoError(t, err)
+		assert.Equal(t, want.Clicks, stats.Clicks, class)
+		assert.Equal(t, want.Variants, stats.Variants, class)
+	}
+
+	// переходы и варианты считаются за тот же период, что и остальная статистика
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	stats, err := s.GetStats(ctx, "", "ab", storage.StatsQuery{From: tomorrow})
+	require.NoError(t, err)
+	assert.Zero(t, stats.Clicks)
+	assert.Nil(t, stats.Variants)
+	assert.Equal(t, tomorrow.Format(time.DateOnly), stats.From)
+
+	stats, err = s.GetStats(ctx, "", "ab", storage.StatsQuery{To: tomorrow})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+}
+
+func TestStorage_Breakdown(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://web.telegram.org"})
+	require.NoError(t, err)
+
+	for _, c := range []storage.Click{
+		{URLID: id, Referrer: "t.me", Source: "newsletter", Browser: "chrome"},
+		{URLID: id, Referrer: "t.me", Browser: "chrome"},
+		{URLID: id, Referrer: "vk.com", Source: "newsletter", Browser: "safari"},
+		{URLID: id, Browser: "firefox"},
+		{URLID: id, Referrer: "slack.com", Browser: "other", Bot: true},
+	} {
+		require.NoError(t, s.SaveClick(ctx, c))
+	}
+
+	want := storage.Breakdown{
+		Referrers: []storage.Count{{Value: "t.me", Clicks: 2}, {Value: "vk.com", Clicks: 1}},
+		Sources:   []storage.Count{{Value: "newsletter", Clicks: 2}},
+		Browsers:  []storage.Count{{Value: "chrome", Clicks: 2}, {Value: "firefox", Clicks: 1}},
+	}
+
+	b, err := s.Breakdown(ctx, "", "tg", storage.StatsQuery{Class: storage.ClassHuman}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, want, b)
+
+	// вне периода переходов нет
+	b, err = s.Breakdown(ctx, "", "tg", storage.StatsQuery{From: time.Now().AddDate(0, 0, 2)}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, storage.Breakdown{}, b)
+
+	// после свёртки в дневные агрегаты статистика не меняется
+	n, err := s.RollupClicks(ctx, time.Now().AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	b, err = s.Breakdown(ctx, "", "tg", storage.StatsQuery{Class: storage.ClassHuman}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, want, b)
+
+	stats, err := s.GetStats(ctx, "", "tg", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stats.Clicks)
+
+	// новые переходы того же дня прибавляются к агрегату
+	require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: id, Referrer: "t.me", Browser: "chrome"}))
+	_, err = s.RollupClicks(ctx, time.Now().AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	b, err = s.Breakdown(ctx, "", "tg", storage.StatsQuery{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Count{{Value: "t.me", Clicks: 3}}, b.Referrers)
+
+	// свежие переходы не сворачиваются
+	require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: id}))
+	n, err = s.RollupClicks(ctx, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	stats, err = s.GetStats(ctx, "", "tg", storage.StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), stats.Clicks)
+}
+
+func TestStorage_UniqueVisitors(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, storage.URL{Alias: "tg", URL: "https://web.telegram.org"})
+	require.NoError(t, err)
+
+	// 3 посетителя, повторные переходы не увеличивают оценку
+	for _, v := range []uint64{0x1111111111111111, 0x2222222222222222, 0x3333333333333333, 0x1111111111111111} {
+		require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: id, Visitor: v}))
+	}
+	// переход без хэша посетителя в уникальные не попадает
+	require.NoError(t, s.SaveClick(ctx, storage.Click{URLID: id}))
+
+	today := time.Now().UTC()
+
+	n, err := s.UniqueVisitors(ctx, "", "tg", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	n, err = s.UniqueVisitors(ctx, "", "tg", today, today)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	n, err = s.UniqueVisitors(ctx, "", "tg", today.AddDate(0, 0, 1), time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// соль одна на день и меняется на следующий
+	salt, err := s.VisitorSalt(ctx, "2024-03-05")
+	require.NoError(t, err)
+	again, err := s.VisitorSalt(ctx, "2024-03-05")
+	require.NoError(t, err)
+	assert.Equal(t, salt, again)
+
+	next, err := s.VisitorSalt(ctx, "2024-03-06")
+	require.NoError(t, err)
+	assert.NotEqual(t, salt, next)
+}
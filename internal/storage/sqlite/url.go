@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// колонки ссылки, которые задаются при сохранении, в порядке urlArgs
+// при чтении к ним добавляются id и счётчики (urlSelectColumns, порядок как в scanURL)
+const (
+	urlColumns       = "domain, alias, url, redirect_status, forward_query, forward_path, query_conflict, utm_template, password_hash, max_clicks, active_from, expires_at, fallback_url, title, created_at, targets, variants"
+	urlPlaceholders  = "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+	urlSelectColumns = "id, " + urlColumns + ", clicks_used"
+)
+
+func urlArgs(u storage.URL) []any {
+	return []any{
+		u.Domain, u.Alias, u.URL, u.RedirectStatus, u.ForwardQuery, u.ForwardPath, u.QueryConflict, u.UTMTemplate, u.PasswordHash,
+		u.MaxClicks, utc(u.ActiveFrom), utc(u.ExpiresAt), u.FallbackURL, u.Title, utc(u.CreatedAt), listJSON(u.Targets), listJSON(u.Variants),
+	}
+}
+
+func scanURL(row interface{ Scan(dest ...any) error }) (storage.URL, error) {
+	var u storage.URL
+	var clicksUsed int64
+	var targets, variants string
+	err := row.Scan(
+		&u.ID, &u.Domain, &u.Alias, &u.URL, &u.RedirectStatus, &u.ForwardQuery, &u.ForwardPath, &u.QueryConflict, &u.UTMTemplate, &u.PasswordHash,
+		&u.MaxClicks, &u.ActiveFrom, &u.ExpiresAt, &u.FallbackURL, &u.Title, &u.CreatedAt, &targets, &variants, &clicksUsed,
+	)
+	if err != nil {
+		return storage.URL{}, err
+	}
+	u.RemainingClicks = remainingClicks(u.MaxClicks, clicksUsed)
+
+	if targets != "" {
+		if err := json.Unmarshal([]byte(targets), &u.Targets); err != nil {
+			return storage.URL{}, fmt.Errorf("decode targets: %w", err)
+		}
+	}
+	if variants != "" {
+		if err := json.Unmarshal([]byte(variants), &u.Variants); err != nil {
+			return storage.URL{}, fmt.Errorf("decode variants: %w", err)
+		}
+	}
+
+	return u, nil
+}
+
+// списки ссылки (targets, variants) храним в json, пустая строка - список пуст
+func listJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	// структуры из строк, чисел и указателей всегда кодируются без ошибки
+	data, _ := json.Marshal(items)
+
+	return string(data)
+}
+
+// время храним в UTC, nil - NULL
+func utc(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+// остаток переходов для ссылки с ограничением, для остальных nil
+func remainingClicks(maxClicks int64, clicksUsed int64) *int64 {
+	if maxClicks <= 0 {
+		return nil
+	}
+
+	remaining := max(maxClicks-clicksUsed, 0)
+	return &remaining
+}
+
+// int64 - это индекс созданной записи
+func (s *Storage) SaveURL(ctx context.Context, u storage.URL) (_ int64, err error) {
+	const op = "storage.sqlite.SaveURL"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	// алиас можно создать только в зарегистрированном домене
+	if u.Domain != "" {
+		if err := s.checkDomain(ctx, u.Domain); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if u.CreatedAt == nil {
+		now := time.Now()
+		u.CreatedAt = &now
+	}
+
+	// вставляем новую запись(новый url)
+	res, err := s.saveURLStmt.ExecContext(ctx, urlArgs(u)...)
+	if err != nil {
+		// проверка на ошибку, что введён алиас, который уже существует в этом домене
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем id
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get last insert id: %w", op, err)
+	}
+
+	s.notify(u.Domain, u.Alias)
+
+	return id, nil
+}
+
+// получаем url для редиректа по host запроса и алиасу
+// если host - зарегистрированный домен, алиас ищется в нём, иначе в общем пространстве
+func (s *Storage) GetURL(ctx context.Context, host string, alias string) (_ storage.URL, err error) {
+	const op = "storage.sqlite.GetURL"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	u, err := scanURL(s.resolveURLStmt.QueryRowContext(ctx, alias, host))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.URL{}, storage.ErrURLNotFound
+		}
+		return storage.URL{}, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+
+	return u, nil
+}
+
+// удаляем url вместе со статистикой
+func (s *Storage) DeleteURL(ctx context.Context, domain string, alias string) (err error) {
+	const op = "storage.sqlite.Delete"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	var resURL string
+	err = tx.StmtContext(ctx, s.getURLStmt).QueryRowContext(ctx, domain, alias).Scan(&id, &resURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrURLNotFound
+		}
+		return fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+
+	_, err = tx.StmtContext(ctx, s.deleteURLStmt).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	// вместе с url удаляем и его статистику
+	_, err = tx.StmtContext(ctx, s.deleteClicksStmt).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+	_, err = tx.StmtContext(ctx, s.deleteSketchStmt).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+	_, err = tx.StmtContext(ctx, s.deleteDailyStmt).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:commit transaction: %w", op, err)
+	}
+
+	s.notify(domain, alias)
+
+	return nil
+}
+
+// обновляем url по алиасу
+func (s *Storage) UpdateURL(ctx context.Context, domain string, alias string, newURL string) (err error) {
+	const op = "storage.sqlite.UpdateURL"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	res, err := s.updateURLStmt.ExecContext(ctx, newURL, domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	// если ни одна строка не изменилась, значит такого алиаса нет
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	s.notify(domain, alias)
+
+	return nil
+}
+
+// меняем время работы ссылки, nil снимает ограничение
+func (s *Storage) UpdateWindow(ctx context.Context, domain string, alias string, activeFrom *time.Time, expiresAt *time.Time) (err error) {
+	const op = "storage.sqlite.UpdateWindow"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	res, err := s.updateWindowStmt.ExecContext(ctx, utc(activeFrom), utc(expiresAt), domain, alias)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	s.notify(domain, alias)
+
+	return nil
+}
+
+// список сохранённых url домена постранично
+func (s *Storage) ListURLs(ctx context.Context, domain string, limit int, offset int) (_ []storage.URL, err error) {
+	const op = "storage.sqlite.ListURLs"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	rows, err := s.listURLsStmt.QueryContext(ctx, domain, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	urls := []storage.URL{}
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:scan row: %w", op, err)
+		}
+		urls = append(urls, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:iterate rows: %w", op, err)
+	}
+
+	return urls, nil
+}
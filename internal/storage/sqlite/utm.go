@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// сохраняем шаблон utm параметров
+func (s *Storage) SaveUTMTemplate(ctx context.Context, t storage.UTMTemplate) (err error) {
+	const op = "storage.sqlite.SaveUTMTemplate"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	_, err = s.saveUTMTemplateStmt.ExecContext(ctx, t.Name, t.Source, t.Medium, t.Campaign, t.Content, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUTMTemplateExists)
+		}
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetUTMTemplate(ctx context.Context, name string) (_ storage.UTMTemplate, err error) {
+	const op = "storage.sqlite.GetUTMTemplate"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	var t storage.UTMTemplate
+	err = s.getUTMTemplateStmt.QueryRowContext(ctx, name).
+		Scan(&t.Name, &t.Source, &t.Medium, &t.Campaign, &t.Content, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.UTMTemplate{}, storage.ErrUTMTemplateNotFound
+		}
+		return storage.UTMTemplate{}, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+
+	return t, nil
+}
+
+func (s *Storage) ListUTMTemplates(ctx context.Context) (_ []storage.UTMTemplate, err error) {
+	const op = "storage.sqlite.ListUTMTemplates"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	rows, err := s.listUTMTemplatesStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	templates := []storage.UTMTemplate{}
+	for rows.Next() {
+		var t storage.UTMTemplate
+		if err := rows.Scan(&t.Name, &t.Source, &t.Medium, &t.Campaign, &t.Content, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s:scan row: %w", op, err)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:iterate rows: %w", op, err)
+	}
+
+	return templates, nil
+}
+
+// удаляем шаблон, уже созданные по нему ссылки не меняются: параметры хранятся в их url
+func (s *Storage) DeleteUTMTemplate(ctx context.Context, name string) (err error) {
+	const op = "storage.sqlite.DeleteUTMTemplate"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	res, err := s.deleteUTMTemplateStmt.ExecContext(ctx, name)
+	if err != nil {
+		return fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrUTMTemplateNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/lib/hll"
+)
+
+// размер соли для хэша посетителя
+const saltSize = 32
+
+// добавляем посетителя в скетч ссылки за день, вызывается в транзакции сохранения перехода
+// транзакция уже держит блокировку записи (useClick), поэтому чтение и запись скетча не пересекаются
+func (s *Storage) addVisitor(ctx context.Context, tx *sql.Tx, urlID int64, day string, visitor uint64) error {
+	sketch := hll.New()
+
+	var data []byte
+	err := tx.StmtContext(ctx, s.getSketchStmt).QueryRowContext(ctx, urlID, day).Scan(&data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("get sketch: %w", err)
+	default:
+		sketch, err = hll.FromBytes(data)
+		if err != nil {
+			return fmt.Errorf("decode sketch: %w", err)
+		}
+	}
+
+	sketch.Add(visitor)
+
+	if _, err := tx.StmtContext(ctx, s.saveSketchStmt).ExecContext(ctx, urlID, day, sketch.Bytes()); err != nil {
+		return fmt.Errorf("save sketch: %w", err)
+	}
+
+	return nil
+}
+
+// оценка числа уникальных посетителей ссылки с from по to включительно (UTC), нулевое время - без ограничения
+func (s *Storage) UniqueVisitors(ctx context.Context, domain string, alias string, from time.Time, to time.Time) (_ int64, err error) {
+	const op = "storage.sqlite.UniqueVisitors"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	fromDay, toDay := dayRange(from, to)
+
+	rows, err := s.listSketchesStmt.QueryContext(ctx, domain, alias, fromDay, toDay)
+	if err != nil {
+		return 0, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	// соль меняется каждый день, поэтому посетитель, приходивший в разные дни, считается в каждом из них
+	total := hll.New()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return 0, fmt.Errorf("%s:scan row: %w", op, err)
+		}
+
+		sketch, err := hll.FromBytes(data)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		total.Merge(sketch)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s:iterate rows: %w", op, err)
+	}
+
+	return int64(total.Count()), nil
+}
+
+// соль для хэша посетителя на день, создаётся при первом запросе, соль прошлых дней удаляется
+func (s *Storage) VisitorSalt(ctx context.Context, day string) (_ []byte, err error) {
+	const op = "storage.sqlite.VisitorSalt"
+
+	ctx, end := startOp(ctx, op)
+	defer end(&err)
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("%s: generate salt: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s:begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.StmtContext(ctx, s.deleteSaltsStmt).ExecContext(ctx, day); err != nil {
+		return nil, fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+
+	// если соль на этот день уже создал другой экземпляр сервиса, берём её
+	if _, err := tx.StmtContext(ctx, s.saveSaltStmt).ExecContext(ctx, day, salt); err != nil {
+		return nil, fmt.Errorf("%s:exec statement: %w", op, err)
+	}
+	if err := tx.StmtContext(ctx, s.getSaltStmt).QueryRowContext(ctx, day).Scan(&salt); err != nil {
+		return nil, fmt.Errorf("%s:execute statement: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s:commit transaction: %w", op, err)
+	}
+
+	return salt, nil
+}
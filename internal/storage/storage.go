@@ -1,6 +1,10 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"slices"
+	"time"
+)
 
 // интерфейсы будем реализовывать в месте использования
 // здесь общий storage для разных хранилищ
@@ -8,4 +12,196 @@ import "errors"
 var (
 	ErrURLNotFound = errors.New("url not found")
 	ErrURLExists   = errors.New("url exists")
+	// у ссылки закончились переходы (max_clicks)
+	ErrURLExhausted = errors.New("url exhausted")
+
+	ErrDomainNotFound = errors.New("domain not found")
+	ErrDomainExists   = errors.New("domain exists")
+	ErrDomainInUse    = errors.New("domain has urls")
+
+	ErrUTMTemplateNotFound = errors.New("utm template not found")
+	ErrUTMTemplateExists   = errors.New("utm template exists")
+)
+
+// ошибки, которые означают обычный ответ хранилища (записи нет, запись уже есть), а не сбой
+func IsExpected(err error) bool {
+	return errors.Is(err, ErrURLNotFound) || errors.Is(err, ErrURLExists) || errors.Is(err, ErrURLExhausted) ||
+		errors.Is(err, ErrDomainNotFound) || errors.Is(err, ErrDomainExists) || errors.Is(err, ErrDomainInUse) ||
+		errors.Is(err, ErrUTMTemplateNotFound) || errors.Is(err, ErrUTMTemplateExists)
+}
+
+// что делать, если параметр из запроса уже есть в целевом url
+const (
+	QueryConflictKeep     = "keep"     // оставляем значение из целевого url (по умолчанию)
+	QueryConflictOverride = "override" // заменяем значением из запроса
+	QueryConflictAppend   = "append"   // передаём оба значения
 )
+
+// запись о сохранённом url
+// Domain - домен, в котором живёт алиас, пустая строка - общее пространство алиасов
+// RedirectStatus - код ответа редиректа (301, 302, 307, 308), 0 - код по умолчанию из конфига
+// ForwardQuery - добавлять параметры запроса к целевому url, конфликты решаются по QueryConflict
+// ForwardPath - дописывать путь после алиаса (/{alias}/extra/path) к целевому url
+// UTMTemplate - шаблон, по которому собраны utm параметры url, подстановки в них раскрываются при переходе
+// PasswordHash - bcrypt хэш пароля, пусто - ссылка открывается без пароля
+// MaxClicks - сколько раз можно перейти по ссылке, 0 - без ограничения
+// RemainingClicks - сколько переходов осталось, заполняется только для ссылок с MaxClicks
+// ActiveFrom, ExpiresAt - ссылка работает с ActiveFrom и до ExpiresAt, nil - без ограничения
+// FallbackURL - куда отправлять, если ссылка истекла, закончились переходы или ещё не начала работать
+// Title - название ссылки для страницы просмотра, CreatedAt - время создания (nil у старых ссылок)
+// Targets - другие адреса по User-Agent, выбирается первый подходящий, если подходящих нет - URL
+// Variants - варианты a/b теста для посетителей, которым не подошёл ни один из Targets, вместо URL
+type URL struct {
+	ID              int64      `json:"id"`
+	Domain          string     `json:"domain,omitempty"`
+	Alias           string     `json:"alias"`
+	URL             string     `json:"url"`
+	RedirectStatus  int        `json:"redirect_status,omitempty"`
+	ForwardQuery    bool       `json:"forward_query,omitempty"`
+	ForwardPath     bool       `json:"forward_path,omitempty"`
+	QueryConflict   string     `json:"query_conflict,omitempty"`
+	UTMTemplate     string     `json:"utm_template,omitempty"`
+	PasswordHash    string     `json:"-"`
+	MaxClicks       int64      `json:"max_clicks,omitempty"`
+	RemainingClicks *int64     `json:"remaining_clicks,omitempty"`
+	ActiveFrom      *time.Time `json:"active_from,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	FallbackURL     string     `json:"fallback_url,omitempty"`
+	Title           string     `json:"title,omitempty"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	Targets         []Target   `json:"targets,omitempty"`
+	Variants        []Variant  `json:"variants,omitempty"`
+}
+
+// вариант a/b теста: посетители распределяются между вариантами пропорционально Weight
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// переход по ссылке
+// Variant - вариант a/b теста, на который отправили посетителя, пусто - у ссылки нет вариантов
+// Visitor - хэш посетителя для подсчёта уникальных за день, 0 - не учитывать
+// Bot - переход бота: краулера, превью в мессенджере, проверки доступности
+// Referrer - домен страницы, с которой пришёл посетитель, Source - utm_source, Browser - семейство браузера,
+// пустая строка - неизвестно
+type Click struct {
+	URLID    int64
+	Variant  string
+	Visitor  uint64
+	Bot      bool
+	Referrer string
+	Source   string
+	Browser  string
+}
+
+// классы переходов для фильтра статистики, пустая строка - все переходы
+const (
+	ClassHuman = "human"
+	ClassBot   = "bot"
+)
+
+// адрес ссылки для части посетителей, пустое условие подходит всем
+// OS - ios, android, windows, macos, linux, chromeos
+// Device - mobile, tablet, desktop
+// Bot - true только для ботов, false только для людей, nil - для всех
+// Countries - ISO коды стран (RU, US), страна определяется по ip посетителя
+type Target struct {
+	OS        string   `json:"os,omitempty"`
+	Device    string   `json:"device,omitempty"`
+	Bot       *bool    `json:"bot,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// посетитель, для которого выбирается адрес ссылки, пустая строка - неизвестно
+type Visitor struct {
+	OS      string
+	Device  string
+	Bot     bool
+	Country string
+}
+
+// подходит ли адрес посетителю
+func (t Target) Matches(v Visitor) bool {
+	return (t.OS == "" || t.OS == v.OS) &&
+		(t.Device == "" || t.Device == v.Device) &&
+		(t.Bot == nil || *t.Bot == v.Bot) &&
+		(len(t.Countries) == 0 || slices.Contains(t.Countries, v.Country))
+}
+
+// ссылка ещё не начала работать
+func (u URL) NotActiveYet(now time.Time) bool {
+	return u.ActiveFrom != nil && now.Before(*u.ActiveFrom)
+}
+
+// срок действия ссылки истёк
+func (u URL) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// окончание работы ссылки должно быть позже начала
+func ValidWindow(activeFrom *time.Time, expiresAt *time.Time) bool {
+	return activeFrom == nil || expiresAt == nil || expiresAt.After(*activeFrom)
+}
+
+// статистика по алиасу
+// все числа считаются по одним и тем же переходам: за период From - To (YYYY-MM-DD, пусто - без ограничения)
+// и класса Class (пусто - все переходы)
+type Stats struct {
+	Domain string `json:"domain,omitempty"`
+	Alias  string `json:"alias"`
+	URL    string `json:"url"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Class  string `json:"class,omitempty"`
+	Clicks int64  `json:"clicks"`
+	// сколько переходов осталось у ссылки с ограничением
+	RemainingClicks *int64 `json:"remaining_clicks,omitempty"`
+	// переходы по вариантам a/b теста
+	Variants map[string]int64 `json:"variants,omitempty"`
+	// оценка числа уникальных посетителей за период, заполняет обработчик статистики
+	// nil для переходов ботов: боты в уникальные посетители не попадают
+	UniqueVisitors *int64 `json:"unique_visitors,omitempty"`
+	// самые частые источники переходов за период, заполняет обработчик статистики
+	Breakdown
+}
+
+// значение и число переходов с ним
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// самые частые домены referrer, utm_source и семейства браузеров, по убыванию числа переходов
+type Breakdown struct {
+	Referrers []Count `json:"referrers,omitempty"`
+	Sources   []Count `json:"utm_sources,omitempty"`
+	Browsers  []Count `json:"browsers,omitempty"`
+}
+
+// какие переходы учитывать в статистике: дни с From по To включительно (UTC, нулевое время - без ограничения),
+// Class - ClassHuman, ClassBot или пусто (все переходы)
+type StatsQuery struct {
+	From  time.Time
+	To    time.Time
+	Class string
+}
+
+// именованный набор utm параметров для ссылок кампании
+// в значениях можно использовать подстановки {date}, {year}, {month}, {alias} и {host}
+type UTMTemplate struct {
+	Name      string    `json:"name"`
+	Source    string    `json:"source"`
+	Medium    string    `json:"medium,omitempty"`
+	Campaign  string    `json:"campaign,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// зарегистрированный домен для коротких ссылок
+type Domain struct {
+	Host      string    `json:"host"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,540 @@
+// Package client - клиент для API url-shortener.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"url-shortener/internal/storage"
+)
+
+// ошибки сервера, которые можно проверить через errors.Is
+// это ошибки хранилища, поэтому errors.Is одинаково работает с client.ErrURLExists и storage.ErrURLExists
+var (
+	ErrURLExists      = storage.ErrURLExists
+	ErrURLNotFound    = storage.ErrURLNotFound
+	ErrDomainNotFound = storage.ErrDomainNotFound
+)
+
+// ошибка, которую вернул сервер
+type APIError struct {
+	StatusCode int
+	Message    string
+	// код ошибки сервера, пустой для ошибок без кода
+	Code string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+// сопоставляем код ошибки сервера с ошибками хранилища, текст ошибки для этого не используется
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case codeURLExists:
+		return ErrURLExists
+	case codeURLNotFound:
+		return ErrURLNotFound
+	case codeDomainNotFound:
+		return ErrDomainNotFound
+	}
+
+	return nil
+}
+
+// сохранённая ссылка, при сохранении через SaveLink ID не указывается
+type Link struct {
+	ID             int64  `json:"id,omitempty"`
+	Domain         string `json:"domain,omitempty"`
+	Alias          string `json:"alias,omitempty"`
+	URL            string `json:"url"`
+	RedirectStatus int    `json:"redirect_status,omitempty"`
+	ForwardQuery   bool   `json:"forward_query,omitempty"`
+	ForwardPath    bool   `json:"forward_path,omitempty"`
+	QueryConflict  string `json:"query_conflict,omitempty"`
+	UTMTemplate    string `json:"utm_template,omitempty"`
+	// пароль задаётся только при сохранении, сервер его не возвращает
+	Password  string `json:"password,omitempty"`
+	MaxClicks int64  `json:"max_clicks,omitempty"`
+	// сколько переходов осталось, только для ссылок с MaxClicks
+	RemainingClicks *int64 `json:"remaining_clicks,omitempty"`
+	// время начала и окончания работы ссылки
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	// куда отправлять, когда ссылка истекла или закончились переходы
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// название для страницы просмотра, время создания заполняет сервер
+	Title     string     `json:"title,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// адреса по системе и устройству посетителя, URL - если ни один не подошёл
+	Targets []Target `json:"targets,omitempty"`
+	// варианты a/b теста вместо URL для посетителей, которым не подошёл ни один из Targets
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// вариант a/b теста, посетители распределяются пропорционально Weight
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// адрес ссылки для части посетителей, пустое условие подходит всем
+// OS - ios, android, windows, macos, linux, chromeos; Device - mobile, tablet, desktop
+// Bot - true только для ботов, false только для людей, nil - для всех
+// Countries - ISO коды стран (RU, US), страна определяется по ip посетителя
+type Target struct {
+	OS        string   `json:"os,omitempty"`
+	Device    string   `json:"device,omitempty"`
+	Bot       *bool    `json:"bot,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// статистика по ссылке, все числа считаются за период From - To (YYYY-MM-DD, пусто - за всё время)
+// и по переходам класса Class (пусто - все переходы)
+type Stats struct {
+	Domain string `json:"domain,omitempty"`
+	Alias  string `json:"alias"`
+	URL    string `json:"url"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Class  string `json:"class,omitempty"`
+	Clicks int64  `json:"clicks"`
+	// сколько переходов осталось, только для ссылок с ограничением
+	RemainingClicks *int64 `json:"remaining_clicks,omitempty"`
+	// переходы по вариантам a/b теста
+	Variants map[string]int64 `json:"variants,omitempty"`
+	// оценка числа уникальных посетителей, для Class = ClassBot всегда 0: боты в уникальные не попадают
+	UniqueVisitors int64 `json:"unique_visitors"`
+	// самые частые домены referrer, utm_source и браузеры, по убыванию числа переходов
+	Referrers []Count `json:"referrers,omitempty"`
+	Sources   []Count `json:"utm_sources,omitempty"`
+	Browsers  []Count `json:"browsers,omitempty"`
+}
+
+// значение и число переходов с ним
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// классы переходов для StatsOptions.Class
+const (
+	ClassHuman = "human"
+	ClassBot   = "bot"
+)
+
+// параметры статистики, нулевые значения - по умолчанию сервера
+// From, To - дни (UTC) включительно, Class - ClassHuman или ClassBot, Top - длина списков источников
+type StatsOptions struct {
+	From  time.Time
+	To    time.Time
+	Class string
+	Top   int
+}
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+
+	user     string
+	password string
+
+	// домен, в котором работают алиасы, пусто - общее пространство
+	domain string
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// авторизация для запросов к /url
+func WithBasicAuth(user, password string) Option {
+	return func(c *Client) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// все алиасы клиента создаются и ищутся в зарегистрированном домене
+func WithDomain(host string) Option {
+	return func(c *Client) {
+		c.domain = host
+	}
+}
+
+// свой http клиент (таймауты, транспорт и т.д.)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// количество повторов при 5xx/429 и границы паузы между ними
+// при 5xx повторяются только идемпотентные запросы, сохранение ссылки (POST) повторяется только при 429
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	const op = "client.New"
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%s: invalid base url %q", op, baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		maxRetries: 3,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// общий ответ сервера
+type response struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// коды ошибок в ответе сервера, часть api, в отличие от текста ошибки
+const (
+	codeURLExists      = "url_exists"
+	codeURLNotFound    = "url_not_found"
+	codeDomainNotFound = "domain_not_found"
+)
+
+// сохраняем url, если alias пустой, сервер сгенерирует его сам
+func (c *Client) Save(ctx context.Context, urlToSave string, alias string) (string, error) {
+	return c.SaveLink(ctx, Link{URL: urlToSave, Alias: alias})
+}
+
+// сохраняем ссылку с дополнительными настройками (код редиректа и т.д.), возвращает алиас
+func (c *Client) SaveLink(ctx context.Context, link Link) (string, error) {
+	const op = "client.Save"
+
+	var res struct {
+		response
+		Alias string `json:"alias"`
+	}
+
+	link.ID = 0
+	if c.domain != "" {
+		link.Domain = c.domain
+	}
+
+	if err := c.do(ctx, http.MethodPost, "/url", link, &res); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.Alias, nil
+}
+
+func (c *Client) Update(ctx context.Context, alias string, newURL string) error {
+	const op = "client.Update"
+
+	var res response
+	if err := c.do(ctx, http.MethodPut, c.withDomain("/url/"+url.PathEscape(alias)), map[string]string{"url": newURL}, &res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// меняем время работы ссылки, nil снимает ограничение
+func (c *Client) SetWindow(ctx context.Context, alias string, activeFrom *time.Time, expiresAt *time.Time) error {
+	const op = "client.SetWindow"
+
+	body := struct {
+		ActiveFrom *time.Time `json:"active_from,omitempty"`
+		ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	}{activeFrom, expiresAt}
+
+	var res response
+	if err := c.do(ctx, http.MethodPut, c.withDomain("/url/"+url.PathEscape(alias)+"/window"), body, &res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Client) Delete(ctx context.Context, alias string) error {
+	const op = "client.Delete"
+
+	var res response
+	if err := c.do(ctx, http.MethodDelete, c.withDomain("/url/"+url.PathEscape(alias)), nil, &res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Client) List(ctx context.Context, limit int, offset int) ([]Link, error) {
+	const op = "client.List"
+
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	if c.domain != "" {
+		q.Set("domain", c.domain)
+	}
+
+	path := "/url"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var res struct {
+		response
+		URLs []Link `json:"urls"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.URLs, nil
+}
+
+// статистика ссылки за всё время по всем переходам
+func (c *Client) Stats(ctx context.Context, alias string) (Stats, error) {
+	return c.StatsWithOptions(ctx, alias, StatsOptions{})
+}
+
+// статистика ссылки за период и по классу переходов
+func (c *Client) StatsWithOptions(ctx context.Context, alias string, opts StatsOptions) (Stats, error) {
+	const op = "client.Stats"
+
+	q := url.Values{}
+	if !opts.From.IsZero() {
+		q.Set("from", opts.From.UTC().Format(time.DateOnly))
+	}
+	if !opts.To.IsZero() {
+		q.Set("to", opts.To.UTC().Format(time.DateOnly))
+	}
+	if opts.Class != "" {
+		q.Set("class", opts.Class)
+	}
+	if opts.Top > 0 {
+		q.Set("top", strconv.Itoa(opts.Top))
+	}
+	if c.domain != "" {
+		q.Set("domain", c.domain)
+	}
+
+	path := "/url/" + url.PathEscape(alias) + "/stats"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var res struct {
+		response
+		Stats
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.Stats, nil
+}
+
+// настройки ссылки по алиасу, переход при этом не засчитывается
+func (c *Client) Get(ctx context.Context, alias string) (Link, error) {
+	const op = "client.Get"
+
+	var res struct {
+		response
+		Link
+	}
+	if err := c.do(ctx, http.MethodGet, c.withDomain("/url/"+url.PathEscape(alias)), nil, &res); err != nil {
+		return Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.Link, nil
+}
+
+// возвращает основной url, на который ведёт алиас, не переходя по нему:
+// переход не засчитывается и не расходуется, вариант a/b теста не выбирается
+// адреса из Targets и Variants, которые зависят от посетителя, есть в Get
+func (c *Client) Resolve(ctx context.Context, alias string) (string, error) {
+	const op = "client.Resolve"
+
+	link, err := c.Get(ctx, alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link.URL, nil
+}
+
+// выполняем запрос с json телом и разбираем ответ в out
+func (c *Client) do(ctx context.Context, method, path string, in any, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return decode(resp, out)
+}
+
+// отправляем запрос, повторяя его при 429 и идемпотентный запрос при 5xx с растущей паузой
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	target, err := c.baseURL.Parse(strings.TrimSuffix(c.baseURL.Path, "/") + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build url: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		// передаём traceparent, чтобы запрос попал в тот же трейс на стороне сервиса
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !retryable(method, resp.StatusCode) || attempt >= c.maxRetries {
+			return resp, nil
+		}
+
+		wait := c.backoff(attempt, resp)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// алиасы своего домена в admin api передаются через ?domain=
+func (c *Client) withDomain(path string) string {
+	if c.domain == "" {
+		return path
+	}
+
+	return path + "?" + url.Values{"domain": {c.domain}}.Encode()
+}
+
+// 429 означает, что запрос не обрабатывался, поэтому повторяется для любого метода
+// 5xx у POST не повторяем: сервер мог сохранить ссылку и ответить ошибкой, тогда повтор вернёт ErrURLExists
+// или создаст вторую ссылку со сгенерированным алиасом
+func retryable(method string, code int) bool {
+	if code == http.StatusTooManyRequests {
+		return true
+	}
+	if code < http.StatusInternalServerError {
+		return false
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// экспоненциальная пауза с джиттером, Retry-After от сервера имеет приоритет
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if sec, err := strconv.Atoi(s); err == nil && sec >= 0 {
+			return time.Duration(sec) * time.Second
+		}
+	}
+
+	d := c.minBackoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+
+	// половина паузы фиксированная, половина случайная, чтобы клиенты не приходили одновременно
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
+
+// разбираем json ответ, ошибку сервера превращаем в APIError
+func decode(resp *http.Response, out any) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var res response
+	if err := json.Unmarshal(data, &res); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	if res.Status != "OK" {
+		return &APIError{StatusCode: resp.StatusCode, Message: res.Error, Code: res.Code}
+	}
+
+	if resp.StatusCode >= 400 {
+		return &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
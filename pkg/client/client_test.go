@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/get"
+	"url-shortener/internal/http-server/handlers/url/get/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+	"url-shortener/pkg/client"
+)
+
+func newClient(t *testing.T, h http.HandlerFunc, opts ...client.Option) *client.Client {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	c, err := client.New(ts.URL, append([]client.Option{
+		client.WithBasicAuth("myuser", "mypass"),
+		client.WithRetries(2, time.Millisecond, 5*time.Millisecond),
+	}, opts...)...)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestClient_Save(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "myuser", user)
+		assert.Equal(t, "mypass", pass)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/url", r.URL.Path)
+
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"abc"}`))
+	})
+
+	alias, err := c.Save(context.Background(), "https://google.com", "")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", alias)
+}
+
+func TestClient_Errors(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{
+			name:    "Exists",
+			body:    `{"status":"Error","error":"url already exists","code":"url_exists"}`,
+			wantErr: storage.ErrURLExists,
+		},
+		{
+			name:    "Not found",
+			body:    `{"status":"Error","error":"not found","code":"url_not_found"}`,
+			wantErr: storage.ErrURLNotFound,
+		},
+		{
+			// ошибка определяется по коду, текст может меняться
+			name:    "Reworded message",
+			body:    `{"status":"Error","error":"no such domain","code":"domain_not_found"}`,
+			wantErr: storage.ErrDomainNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tc.body))
+			})
+
+			_, err := c.Save(context.Background(), "https://google.com", "tg")
+			require.ErrorIs(t, err, tc.wantErr)
+
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr)
+		})
+	}
+
+	// без кода ошибка не сопоставляется, даже если текст совпадает
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"Error","error":"not found"}`))
+	})
+
+	_, err := c.Save(context.Background(), "https://google.com", "tg")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, client.ErrURLNotFound)
+}
+
+func TestClient_Retry(t *testing.T) {
+	var calls atomic.Int32
+
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"tg","url":"https://web.telegram.org","clicks":3}`))
+	})
+
+	stats, err := c.Stats(context.Background(), "tg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_RetryExhausted(t *testing.T) {
+	var calls atomic.Int32
+
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	err := c.Delete(context.Background(), "tg")
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_SaveNotRetried(t *testing.T) {
+	var calls atomic.Int32
+
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// ссылка могла сохраниться до ошибки, повтор вернул бы ErrURLExists
+	_, err := c.Save(context.Background(), "https://google.com", "tg")
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_SaveRetriedOnTooManyRequests(t *testing.T) {
+	var calls atomic.Int32
+
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// 429 отдаётся до обработки запроса, повтор безопасен и для POST
+		if calls.Add(1) < 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"tg"}`))
+	})
+
+	alias, err := c.Save(context.Background(), "https://google.com", "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "tg", alias)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_StatsWithOptions(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/url/tg/stats", r.URL.Path)
+		assert.Equal(t, "2024-03-01", r.URL.Query().Get("from"))
+		assert.Equal(t, "2024-03-31", r.URL.Query().Get("to"))
+		assert.Equal(t, client.ClassHuman, r.URL.Query().Get("class"))
+		assert.Equal(t, "5", r.URL.Query().Get("top"))
+
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"tg","from":"2024-03-01","to":"2024-03-31","class":"human",` +
+			`"clicks":3,"referrers":[{"value":"t.me","clicks":2}]}`))
+	})
+
+	stats, err := c.StatsWithOptions(context.Background(), "tg", client.StatsOptions{
+		From:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		Class: client.ClassHuman,
+		Top:   5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+	assert.Equal(t, "2024-03-01", stats.From)
+	assert.Equal(t, []client.Count{{Value: "t.me", Clicks: 2}}, stats.Referrers)
+}
+
+func TestClient_Resolve(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "", "tg").
+		Return(storage.URL{Alias: "tg", URL: "https://web.telegram.org", MaxClicks: 1}, nil).Once()
+	urlGetterMock.On("GetURL", mock.Anything, "", "missing").Return(storage.URL{}, storage.ErrURLNotFound).Once()
+
+	// настоящий обработчик сервера: ссылка читается без перехода по ней
+	router := chi.NewRouter()
+	router.Get("/url/{alias}", get.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+	c := newClient(t, router.ServeHTTP)
+
+	target, err := c.Resolve(context.Background(), "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://web.telegram.org", target)
+
+	_, err = c.Resolve(context.Background(), "missing")
+	require.ErrorIs(t, err, client.ErrURLNotFound)
+}
+
+func TestClient_Domain(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// алиасы домена передаются через ?domain=
+		assert.Equal(t, "go.example.com", r.URL.Query().Get("domain"))
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"tg","url":"https://web.telegram.org"}`))
+	}, client.WithDomain("go.example.com"))
+
+	require.NoError(t, c.Update(context.Background(), "tg", "https://google.com"))
+
+	target, err := c.Resolve(context.Background(), "tg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://web.telegram.org", target)
+}
+
+func TestClient_SaveLink(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"url": "https://google.com", "redirect_status": float64(301)}, body)
+
+		_, _ = w.Write([]byte(`{"status":"OK","alias":"abc"}`))
+	})
+
+	alias, err := c.SaveLink(context.Background(), client.Link{URL: "https://google.com", RedirectStatus: http.StatusMovedPermanently})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", alias)
+}